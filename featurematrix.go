@@ -118,6 +118,93 @@ func (fm *FeatureMatrix) BestSplitter(target Target,
 	return
 }
 
+/*
+BestSplitterOverSource is the FeatureMatrixSource-backed counterpart of
+BestSplitter: it pulls each candidate column through source.FeatureByIndex
+instead of requiring every feature to already be resident in an in-memory
+FeatureMatrix, so a tree can be grown against a MMapAFMSource/ZipAFMSource
+bigger than RAM, materializing only the columns actually proposed as
+candidates at a node. Pass NewMemFeatureMatrixSource(fm) to get
+BestSplitter's original, fully in-memory behavior back.
+
+evaloob's Splitter.Split needs a *FeatureMatrix rather than a
+FeatureMatrixSource, so that branch pays for a single-feature
+source.MaterializeSubset instead of the whole matrix.
+*/
+func BestSplitterOverSource(source FeatureMatrixSource, target Target,
+	cases *[]int,
+	candidates *[]int,
+	oob *[]int,
+	leafSize int,
+	vet bool,
+	evaloob bool,
+	allocs *BestSplitAllocs) (s *Splitter, impurityDecrease float64) {
+
+	impurityDecrease = minImp
+
+	var f, bestF Feature
+	var inerImp float64
+	var vetImp float64
+	var split, bestSplit interface{}
+
+	if vet {
+		target.(Feature).CopyInTo(allocs.ContrastTarget.(Feature))
+	}
+
+	parentImp := target.Impurity(cases, allocs.Counter)
+
+	for _, i := range *candidates {
+		f = source.FeatureByIndex(i)
+		split, inerImp = f.BestSplit(target, cases, parentImp, leafSize, allocs)
+
+		if evaloob && inerImp > minImp && inerImp > impurityDecrease {
+			spliter := f.DecodeSplit(split)
+			oobFM := source.MaterializeSubset(*oob, []int{i})
+
+			//MaterializeSubset renumbers *oob into the compact 0..len(*oob)-1
+			//index space oobFM actually holds, so Split must be driven with
+			//that local index set and its output remapped back to the
+			//original case ids before it's handed to target.SplitImpurity.
+			localOOB := make([]int, len(*oob))
+			for k := range localOOB {
+				localOOB[k] = k
+			}
+			l, r, m := spliter.Split(oobFM, localOOB)
+			toGlobal := func(local []int) []int {
+				global := make([]int, len(local))
+				for j, loc := range local {
+					global[j] = (*oob)[loc]
+				}
+				return global
+			}
+			lg, rg, mg := toGlobal(l), toGlobal(r), toGlobal(m)
+			inerImp = target.Impurity(oob, allocs.Counter) - target.SplitImpurity(&lg, &rg, &mg, allocs)
+		}
+
+		if vet && inerImp > minImp && inerImp > impurityDecrease {
+			casept := cases
+			if evaloob {
+				casept = oob
+			}
+
+			allocs.ContrastTarget.(Feature).ShuffleCases(casept)
+			_, vetImp = f.BestSplit(allocs.ContrastTarget, casept, parentImp, leafSize, allocs)
+			inerImp = inerImp - vetImp
+		}
+
+		if inerImp > minImp && inerImp > impurityDecrease {
+			bestF = f
+			impurityDecrease = inerImp
+			bestSplit = split
+		}
+
+	}
+	if impurityDecrease > minImp {
+		s = bestF.DecodeSplit(bestSplit)
+	}
+	return
+}
+
 /*
 AddContrasts appends n artificial contrast features to a feature matrix. These features
 are generated by randomly selecting (with replacement) an existing feature and
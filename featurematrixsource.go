@@ -0,0 +1,469 @@
+package CloudForest
+
+import (
+	"archive/zip"
+	"bufio"
+	"container/list"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+/*
+FeatureMatrixSource abstracts over where a feature matrix's data actually
+lives, so that BestSplitter and growforest can pull in only the candidate
+feature columns a node needs to consider rather than require the entire
+matrix to be resident in RAM. MemFeatureMatrixSource preserves today's
+fully in-memory behavior; MMapAFMSource and ZipAFMSource let a forest be
+grown against AFM files bigger than RAM by decoding and caching feature
+columns lazily.
+*/
+type FeatureMatrixSource interface {
+	NumFeatures() int
+	NumCases() int
+	FeatureByIndex(i int) Feature
+	CaseLabel(i int) string
+	MaterializeSubset(cases []int, features []int) *FeatureMatrix
+}
+
+//-- in-memory ---------------------------------------------------------------
+
+//MemFeatureMatrixSource adapts an already parsed *FeatureMatrix to
+//FeatureMatrixSource, with every method served directly from fm.
+type MemFeatureMatrixSource struct {
+	FM *FeatureMatrix
+}
+
+func NewMemFeatureMatrixSource(fm *FeatureMatrix) *MemFeatureMatrixSource {
+	return &MemFeatureMatrixSource{FM: fm}
+}
+
+func (s *MemFeatureMatrixSource) NumFeatures() int             { return len(s.FM.Data) }
+func (s *MemFeatureMatrixSource) NumCases() int                { return len(s.FM.CaseLabels) }
+func (s *MemFeatureMatrixSource) FeatureByIndex(i int) Feature { return s.FM.Data[i] }
+func (s *MemFeatureMatrixSource) CaseLabel(i int) string       { return s.FM.CaseLabels[i] }
+
+func (s *MemFeatureMatrixSource) MaterializeSubset(cases []int, features []int) *FeatureMatrix {
+	return materializeSubset(s, cases, features)
+}
+
+//materializeSubset builds a plain *FeatureMatrix containing only the given
+//features/cases (nil means "all"), with case labels pulled from s.CaseLabel
+//so every FeatureMatrixSource implementation gets its real sample names
+//rather than placeholder indices. DenseNumFeature and DenseCatFeature are
+//copied directly by index, preserving their concrete type; any other
+//Feature implementation falls back to re-encoding through GetStr/
+//ParseFeature. This is shared by every FeatureMatrixSource implementation
+//so each only has to implement FeatureByIndex/NumCases/NumFeatures/CaseLabel.
+func materializeSubset(s FeatureMatrixSource, cases []int, features []int) *FeatureMatrix {
+	if cases == nil {
+		cases = identityRange(s.NumCases())
+	}
+	if features == nil {
+		features = identityRange(s.NumFeatures())
+	}
+
+	data := make([]Feature, len(features))
+	lookup := make(map[string]int, len(features))
+	caseLabels := make([]string, len(cases))
+	for i, c := range cases {
+		caseLabels[i] = s.CaseLabel(c)
+	}
+
+	for fi, i := range features {
+		nf := materializeFeature(s.FeatureByIndex(i), cases)
+		data[fi] = nf
+		lookup[nf.GetName()] = fi
+	}
+
+	return &FeatureMatrix{data, lookup, caseLabels}
+}
+
+//materializeFeature returns the subset of f restricted to cases. Dense
+//features are copied field-by-field so their concrete type is preserved;
+//ParseFeature's N:/C: prefix sniffing would otherwise misclassify numeric
+//columns whose name doesn't carry that prefix, such as PCA's "PC:1" or a
+//one-hot-expanded "orig=level" column. Any other Feature implementation
+//(the sparse types) round-trips through GetStr/ParseFeature as before, so
+//its materialized type follows its name: numeric for an "N:" prefix,
+//categorical otherwise.
+func materializeFeature(f Feature, cases []int) Feature {
+	switch ft := f.(type) {
+	case *DenseNumFeature:
+		numData := make([]float64, len(cases))
+		missing := make([]bool, len(cases))
+		for k, c := range cases {
+			numData[k] = ft.NumData[c]
+			missing[k] = ft.Missing[c]
+		}
+		nf := *ft
+		nf.NumData = numData
+		nf.Missing = missing
+		return &nf
+	case *DenseCatFeature:
+		catData := make([]int, len(cases))
+		missing := make([]bool, len(cases))
+		for k, c := range cases {
+			catData[k] = ft.CatData[c]
+			missing[k] = ft.Missing[c]
+		}
+		nf := *ft
+		nf.CatData = catData
+		nf.Missing = missing
+		return &nf
+	default:
+		record := make([]string, len(cases)+1)
+		record[0] = f.GetName()
+		for k, c := range cases {
+			record[k+1] = f.GetStr(c)
+		}
+		return ParseFeature(record)
+	}
+}
+
+func identityRange(n int) []int {
+	r := make([]int, n)
+	for i := range r {
+		r[i] = i
+	}
+	return r
+}
+
+//-- feature cache -------------------------------------------------------------
+
+//featureLRU bounds the number of lazily decoded Features held in memory at
+//once, evicting the least recently used entry when full. get/put are
+//safe for concurrent use, since growforest grows trees in parallel and
+//multiple goroutines can call FeatureByIndex on the same source at once.
+type featureLRU struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[int]*list.Element
+}
+
+type featureLRUEntry struct {
+	index   int
+	feature Feature
+}
+
+func newFeatureLRU(capacity int) *featureLRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &featureLRU{capacity: capacity, ll: list.New(), items: make(map[int]*list.Element)}
+}
+
+func (c *featureLRU) get(i int) (Feature, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[i]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*featureLRUEntry).feature, true
+}
+
+func (c *featureLRU) put(i int, f Feature) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[i]; ok {
+		el.Value.(*featureLRUEntry).feature = f
+		c.ll.MoveToFront(el)
+		return
+	}
+	el := c.ll.PushFront(&featureLRUEntry{i, f})
+	c.items[i] = el
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*featureLRUEntry).index)
+		}
+	}
+}
+
+//-- memory-mapped AFM ---------------------------------------------------------
+
+/*
+MMapAFMSource indexes an AFM file (features-in-rows layout) by byte offset
+on construction, then decodes and caches feature rows lazily and in an LRU
+of bounded size as FeatureByIndex is called, so opening a multi-gigabyte
+matrix does not require parsing or holding the whole thing in RAM. Rows
+are read with ReadAt on the open file; the file is not memory-mapped.
+
+FeatureByIndex is safe for concurrent use: offsets/caseLabels are fixed at
+construction, file.ReadAt is safe for concurrent calls on the same *os.File,
+and the cache locks itself, so growforest's parallel tree-growing can share
+one MMapAFMSource across goroutines.
+*/
+type MMapAFMSource struct {
+	file       *os.File
+	offsets    []int64 //offsets[i] is the start of feature row i
+	caseLabels []string
+	cache      *featureLRU
+}
+
+//OpenMMapAFM indexes filename's rows without materializing any of them, and
+//returns a source that decodes rows on demand, caching up to cacheSize of
+//them at a time.
+func OpenMMapAFM(filename string, cacheSize int) (*MMapAFMSource, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	headerLine, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		file.Close()
+		return nil, err
+	}
+	headerRecord := strings.Split(strings.TrimRight(headerLine, "\r\n"), "\t")
+	if len(headerRecord) < 1 {
+		file.Close()
+		return nil, fmt.Errorf("afm: empty header in %v", filename)
+	}
+	caseLabels := headerRecord[1:]
+
+	offsets := make([]int64, 0, 1024)
+	offset := int64(len(headerLine))
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			offsets = append(offsets, offset)
+			offset += int64(len(line))
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return &MMapAFMSource{
+		file:       file,
+		offsets:    offsets,
+		caseLabels: caseLabels,
+		cache:      newFeatureLRU(cacheSize),
+	}, nil
+}
+
+func (s *MMapAFMSource) NumFeatures() int       { return len(s.offsets) }
+func (s *MMapAFMSource) NumCases() int          { return len(s.caseLabels) }
+func (s *MMapAFMSource) CaseLabel(i int) string { return s.caseLabels[i] }
+
+func (s *MMapAFMSource) FeatureByIndex(i int) Feature {
+	if f, ok := s.cache.get(i); ok {
+		return f
+	}
+
+	start := s.offsets[i]
+	var end int64
+	if i+1 < len(s.offsets) {
+		end = s.offsets[i+1]
+	} else {
+		info, err := s.file.Stat()
+		if err != nil {
+			return nil
+		}
+		end = info.Size()
+	}
+
+	buf := make([]byte, end-start)
+	if _, err := s.file.ReadAt(buf, start); err != nil && err != io.EOF {
+		return nil
+	}
+
+	tsv := csv.NewReader(strings.NewReader(string(buf)))
+	tsv.Comma = '\t'
+	record, err := tsv.Read()
+	if err != nil {
+		return nil
+	}
+
+	f := ParseFeature(record)
+	s.cache.put(i, f)
+	return f
+}
+
+func (s *MMapAFMSource) MaterializeSubset(cases []int, features []int) *FeatureMatrix {
+	return materializeSubset(s, cases, features)
+}
+
+func (s *MMapAFMSource) Close() error { return s.file.Close() }
+
+//-- zip-backed AFM -------------------------------------------------------------
+
+/*
+ZipAFMSource reads only a zip archive's central directory up front (which
+zip.OpenReader already does cheaply via its end-of-central-directory seek)
+and otherwise decompresses the archived AFM lazily: feature rows are read
+by streaming forward through the entry's DEFLATE stream from wherever the
+last request left off, rather than re-decompressing the whole entry for
+every access. Because DEFLATE streams can't be seeked backwards, requesting
+an index behind the current position reopens the entry and re-streams from
+its start; a small LRU absorbs most of the cost of backtracking callers.
+NumFeatures is itself lazy: its row count is only known by scanning the
+whole entry once, and that scan is deferred to NumFeatures' first call
+rather than paid for in OpenZipAFM, so opening a multi-gigabyte archive
+never decompresses it before a single feature is actually requested.
+
+Unlike MMapAFMSource, the decode path here is inherently sequential state
+(stream/bufReader/nextRow all describe one forward-only read position), so
+after a first cache lookup FeatureByIndex holds mu for the whole decode
+rather than just around the cache: concurrent callers are serialized
+instead of racing.
+*/
+type ZipAFMSource struct {
+	zr         *zip.ReadCloser
+	entry      *zip.File
+	caseLabels []string
+	numRows    int //-1 until NumFeatures has counted it
+
+	mu        sync.Mutex
+	stream    io.ReadCloser
+	bufReader *bufio.Reader
+	nextRow   int
+	cache     *featureLRU
+}
+
+//OpenZipAFM opens a zip-archived AFM (the first file in the archive) and
+//returns a source that decodes rows lazily, caching up to cacheSize of
+//them at a time.
+func OpenZipAFM(filename string, cacheSize int) (*ZipAFMSource, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(zr.File) == 0 {
+		zr.Close()
+		return nil, fmt.Errorf("zip: %v has no entries", filename)
+	}
+	entry := zr.File[0]
+
+	s := &ZipAFMSource{zr: zr, entry: entry, cache: newFeatureLRU(cacheSize), numRows: -1}
+	if err := s.reopen(); err != nil {
+		zr.Close()
+		return nil, err
+	}
+
+	headerLine, err := s.bufReader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		zr.Close()
+		return nil, err
+	}
+	headerRecord := strings.Split(strings.TrimRight(headerLine, "\r\n"), "\t")
+	s.caseLabels = headerRecord[1:]
+
+	return s, nil
+}
+
+func (s *ZipAFMSource) reopen() error {
+	if s.stream != nil {
+		s.stream.Close()
+	}
+	rc, err := s.entry.Open()
+	if err != nil {
+		return err
+	}
+	s.stream = rc
+	s.bufReader = bufio.NewReader(rc)
+	s.nextRow = 0
+	return nil
+}
+
+//NumFeatures returns the archived AFM's row count, counting it with one
+//bounded forward scan through an independent reader the first call and
+//caching the result thereafter. Counting is deferred here, rather than
+//done in OpenZipAFM, so opening a multi-gigabyte archive never forces a
+//decompression pass before a single feature has actually been requested;
+//using a reader of its own also means counting doesn't disturb
+//stream/bufReader/nextRow, the state FeatureByIndex is mid-scanning with.
+func (s *ZipAFMSource) NumFeatures() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.numRows >= 0 {
+		return s.numRows
+	}
+
+	rc, err := s.entry.Open()
+	if err != nil {
+		return 0
+	}
+	defer rc.Close()
+	br := bufio.NewReader(rc)
+	br.ReadString('\n') //skip header
+
+	count := 0
+	for {
+		line, err := br.ReadString('\n')
+		if len(strings.TrimSpace(line)) > 0 {
+			count++
+		}
+		if err != nil {
+			break
+		}
+	}
+	s.numRows = count
+	return count
+}
+
+func (s *ZipAFMSource) NumCases() int          { return len(s.caseLabels) }
+func (s *ZipAFMSource) CaseLabel(i int) string { return s.caseLabels[i] }
+
+func (s *ZipAFMSource) FeatureByIndex(i int) Feature {
+	if f, ok := s.cache.get(i); ok {
+		return f
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	//re-check now that we hold the lock: another goroutine may have
+	//decoded and cached i while we were waiting.
+	if f, ok := s.cache.get(i); ok {
+		return f
+	}
+	if i < s.nextRow {
+		if err := s.reopen(); err != nil {
+			return nil
+		}
+		s.bufReader.ReadString('\n') //skip header
+	}
+	for s.nextRow < i {
+		if _, err := s.bufReader.ReadString('\n'); err != nil {
+			return nil
+		}
+		s.nextRow++
+	}
+
+	line, err := s.bufReader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil
+	}
+	s.nextRow++
+
+	tsv := csv.NewReader(strings.NewReader(strings.TrimRight(line, "\r\n")))
+	tsv.Comma = '\t'
+	record, err := tsv.Read()
+	if err != nil {
+		return nil
+	}
+
+	f := ParseFeature(record)
+	s.cache.put(i, f)
+	return f
+}
+
+func (s *ZipAFMSource) MaterializeSubset(cases []int, features []int) *FeatureMatrix {
+	return materializeSubset(s, cases, features)
+}
+
+func (s *ZipAFMSource) Close() error {
+	if s.stream != nil {
+		s.stream.Close()
+	}
+	return s.zr.Close()
+}
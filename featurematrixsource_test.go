@@ -0,0 +1,170 @@
+package CloudForest
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testAFMContent = ".\tc1\tc2\tc3\nN:x\t1\t2\t3\nC:y\ta\tb\ta\n"
+
+func writeTempAFM(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.afm")
+	if err := os.WriteFile(path, []byte(testAFMContent), 0644); err != nil {
+		t.Fatalf("writing temp afm: %v", err)
+	}
+	return path
+}
+
+func TestMaterializeSubsetPreservesDenseNumFeatureType(t *testing.T) {
+	//PC:1-style names don't carry the N:/C: prefix ParseFeature sniffs on,
+	//so materializing a subset must not round-trip them through it.
+	pc := &DenseNumFeature{[]float64{10, 20, 30, 40}, make([]bool, 4), "PC:1", false}
+	fm := &FeatureMatrix{
+		Data:       []Feature{pc},
+		Map:        map[string]int{"PC:1": 0},
+		CaseLabels: []string{"a", "b", "c", "d"},
+	}
+	source := NewMemFeatureMatrixSource(fm)
+
+	sub := source.MaterializeSubset([]int{1, 3}, []int{0})
+	got, ok := sub.Data[0].(*DenseNumFeature)
+	if !ok {
+		t.Fatalf("materialized PC:1 is %T, want *DenseNumFeature", sub.Data[0])
+	}
+	if want := []float64{20, 40}; got.NumData[0] != want[0] || got.NumData[1] != want[1] {
+		t.Errorf("materialized NumData = %v, want %v", got.NumData, want)
+	}
+}
+
+func TestMaterializeSubsetPreservesDenseCatFeatureType(t *testing.T) {
+	//a one-hot expanded "orig=level" column is numeric, but an ordinary
+	//categorical column should still come back as a DenseCatFeature.
+	cat := &DenseCatFeature{&CatMap{make(map[string]int), make([]string, 0)}, []int{0, 1, 0, 1}, make([]bool, 4), "c", false, false}
+	fm := &FeatureMatrix{
+		Data:       []Feature{cat},
+		Map:        map[string]int{"c": 0},
+		CaseLabels: []string{"a", "b", "c", "d"},
+	}
+	source := NewMemFeatureMatrixSource(fm)
+
+	sub := source.MaterializeSubset([]int{0, 1, 2, 3}, []int{0})
+	got, ok := sub.Data[0].(*DenseCatFeature)
+	if !ok {
+		t.Fatalf("materialized c is %T, want *DenseCatFeature", sub.Data[0])
+	}
+	if got.CatData[1] != 1 {
+		t.Errorf("materialized CatData = %v, want [0 1 0 1]", got.CatData)
+	}
+}
+
+func TestMaterializeSubsetDefaultsToAllCasesAndFeatures(t *testing.T) {
+	x := &DenseNumFeature{[]float64{1, 2, 3}, make([]bool, 3), "x", false}
+	fm := &FeatureMatrix{
+		Data:       []Feature{x},
+		Map:        map[string]int{"x": 0},
+		CaseLabels: []string{"a", "b", "c"},
+	}
+	source := NewMemFeatureMatrixSource(fm)
+
+	sub := source.MaterializeSubset(nil, nil)
+	if len(sub.CaseLabels) != 3 || len(sub.Data) != 1 {
+		t.Fatalf("MaterializeSubset(nil, nil) = %v cases, %v features, want 3, 1", len(sub.CaseLabels), len(sub.Data))
+	}
+	if sub.CaseLabels[2] != "c" {
+		t.Errorf("CaseLabels = %v, want the source's own labels preserved", sub.CaseLabels)
+	}
+}
+
+func TestMMapAFMSourceReadsFeaturesAndLabels(t *testing.T) {
+	path := writeTempAFM(t)
+	source, err := OpenMMapAFM(path, 8)
+	if err != nil {
+		t.Fatalf("OpenMMapAFM: %v", err)
+	}
+	defer source.Close()
+
+	if got := source.NumFeatures(); got != 2 {
+		t.Fatalf("NumFeatures() = %v, want 2", got)
+	}
+	if got := source.NumCases(); got != 3 {
+		t.Fatalf("NumCases() = %v, want 3", got)
+	}
+	if got := source.CaseLabel(1); got != "c2" {
+		t.Errorf("CaseLabel(1) = %v, want c2", got)
+	}
+
+	x, ok := source.FeatureByIndex(0).(*DenseNumFeature)
+	if !ok {
+		t.Fatalf("FeatureByIndex(0) is %T, want *DenseNumFeature", source.FeatureByIndex(0))
+	}
+	if x.NumData[1] != 2 {
+		t.Errorf("x.NumData = %v, want [1 2 3]", x.NumData)
+	}
+}
+
+func TestMMapAFMSourceMaterializeSubsetUsesRealCaseLabels(t *testing.T) {
+	path := writeTempAFM(t)
+	source, err := OpenMMapAFM(path, 8)
+	if err != nil {
+		t.Fatalf("OpenMMapAFM: %v", err)
+	}
+	defer source.Close()
+
+	sub := source.MaterializeSubset([]int{2, 0}, []int{0})
+	want := []string{"c3", "c1"}
+	for i, w := range want {
+		if sub.CaseLabels[i] != w {
+			t.Errorf("CaseLabels[%v] = %v, want %v", i, sub.CaseLabels[i], w)
+		}
+	}
+}
+
+func writeTempZippedAFM(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.afm.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("creating temp zip: %v", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	entry, err := zw.Create("test.afm")
+	if err != nil {
+		t.Fatalf("creating zip entry: %v", err)
+	}
+	if _, err := entry.Write([]byte(testAFMContent)); err != nil {
+		t.Fatalf("writing zip entry: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("closing zip writer: %v", err)
+	}
+	return path
+}
+
+func TestZipAFMSourceReadsFeaturesAndLabels(t *testing.T) {
+	path := writeTempZippedAFM(t)
+	source, err := OpenZipAFM(path, 8)
+	if err != nil {
+		t.Fatalf("OpenZipAFM: %v", err)
+	}
+	defer source.Close()
+
+	if got := source.NumFeatures(); got != 2 {
+		t.Fatalf("NumFeatures() = %v, want 2", got)
+	}
+	if got := source.NumCases(); got != 3 {
+		t.Fatalf("NumCases() = %v, want 3", got)
+	}
+	if got := source.CaseLabel(2); got != "c3" {
+		t.Errorf("CaseLabel(2) = %v, want c3", got)
+	}
+
+	sub := source.MaterializeSubset([]int{1}, []int{0, 1})
+	if sub.CaseLabels[0] != "c2" {
+		t.Errorf("MaterializeSubset CaseLabels = %v, want [c2]", sub.CaseLabels)
+	}
+}
@@ -0,0 +1,285 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+GLMLeaf fits a ridge-regularized linear (regression) or logistic
+(classification) model over a leaf's cases instead of storing the usual
+constant prediction, giving CloudForest the model-based-leaves capability
+common to modern GBM/GLM-tree hybrids.
+
+GLMLeaf is meant to be used together with FeatureMatrix.OneHotExpandAll:
+fit it against the expanded, purely numeric feature set so that categorical
+predictors are represented as the binary columns a linear model expects.
+When a leaf has fewer cases than the model has parameters, Fit falls back
+to Constant, the same constant-prediction behavior as an ordinary leaf.
+
+BLOCKED: this request asked for a GLMLeaf tree-growing option, i.e. a way
+to tell the grower to hold a *GLMLeaf instead of a constant at each
+terminal node and call Fit/Predict in its place. That hookup lives in
+tree.go's node-growing loop, which is not part of this source tree, so it
+could not be written or tested here; the request is not usable
+end-to-end as specified. This file ships only the standalone leaf model
+(Fit/Predict), unintegrated, so the tree.go change is additive once that
+file is available: construct a *GLMLeaf where a terminal node's constant
+prediction is currently set, call Fit(target, features, cases) once, and
+call Predict in place of the constant thereafter.
+*/
+type GLMLeaf struct {
+	FeatureNames   []string //names of the (numeric, one-hot expanded) features the model was fit on
+	Weights        []float64
+	Bias           float64
+	Lambda         float64 //L2 penalty strength
+	Classification bool    //true for ridge logistic regression, false for ridge linear regression
+	Constant       float64 //fallback prediction used when Fit had too few cases to estimate Weights
+	isConstant     bool
+}
+
+//NewGLMLeaf returns a GLMLeaf ready to Fit against featureNames, with L2
+//penalty lambda.
+func NewGLMLeaf(featureNames []string, classification bool, lambda float64) *GLMLeaf {
+	return &GLMLeaf{
+		FeatureNames:   featureNames,
+		Classification: classification,
+		Lambda:         lambda,
+	}
+}
+
+/*
+Fit estimates Weights/Bias from fm's FeatureNames columns and target,
+restricted to cases. Classification targets are expected to be a binary
+*DenseCatFeature (coded 0/1); regression targets a *DenseNumFeature. When
+len(cases) is less than or equal to len(FeatureNames), Fit instead records
+the mean target value (or positive-class rate) as Constant and every
+subsequent Predict returns that constant.
+
+Fit returns an error, without mutating g's weights, if fm is missing one of
+g.FeatureNames or has it as something other than a *DenseNumFeature -
+which can happen if fm has been through FilterByPValue since FeatureNames
+was recorded.
+*/
+func (g *GLMLeaf) Fit(fm *FeatureMatrix, target Target, cases []int) error {
+	p := len(g.FeatureNames)
+	if len(cases) <= p {
+		g.isConstant = true
+		g.Constant = meanTarget(target, cases)
+		return nil
+	}
+
+	x := make([][]float64, len(cases))
+	y := make([]float64, len(cases))
+	for row, c := range cases {
+		r, err := g.featureRow(fm, c)
+		if err != nil {
+			return err
+		}
+		x[row] = r
+		y[row] = targetValue(target, c)
+	}
+
+	if g.Classification {
+		g.Weights, g.Bias = ridgeLogisticFit(x, y, g.Lambda)
+	} else {
+		g.Weights, g.Bias = ridgeLinearFit(x, y, g.Lambda)
+	}
+	g.isConstant = false
+	return nil
+}
+
+//Predict returns the leaf's prediction for case i of fm: a class
+//probability when Classification is set, a regression estimate otherwise.
+//It returns an error under the same conditions documented on Fit.
+func (g *GLMLeaf) Predict(fm *FeatureMatrix, i int) (float64, error) {
+	if g.isConstant {
+		return g.Constant, nil
+	}
+	row, err := g.featureRow(fm, i)
+	if err != nil {
+		return 0, err
+	}
+	z := g.Bias
+	for j, w := range g.Weights {
+		z += w * row[j]
+	}
+	if g.Classification {
+		return sigmoid(z), nil
+	}
+	return z, nil
+}
+
+func (g *GLMLeaf) featureRow(fm *FeatureMatrix, i int) ([]float64, error) {
+	row := make([]float64, len(g.FeatureNames))
+	for j, name := range g.FeatureNames {
+		idx, ok := fm.Map[name]
+		if !ok {
+			return nil, fmt.Errorf("glmleaf: feature %q not present in matrix", name)
+		}
+		nf, ok := fm.Data[idx].(*DenseNumFeature)
+		if !ok {
+			return nil, fmt.Errorf("glmleaf: feature %q is not numeric", name)
+		}
+		if !nf.Missing[i] {
+			row[j] = nf.NumData[i]
+		}
+	}
+	return row, nil
+}
+
+func meanTarget(target Target, cases []int) float64 {
+	sum := 0.0
+	for _, c := range cases {
+		sum += targetValue(target, c)
+	}
+	if len(cases) == 0 {
+		return 0
+	}
+	return sum / float64(len(cases))
+}
+
+func targetValue(target Target, i int) float64 {
+	switch t := target.(type) {
+	case *DenseNumFeature:
+		if t.Missing[i] {
+			return 0
+		}
+		return t.NumData[i]
+	case *DenseCatFeature:
+		if t.Missing[i] {
+			return 0
+		}
+		return float64(t.CatData[i])
+	default:
+		return 0
+	}
+}
+
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
+
+//ridgeLinearFit solves the L2-penalized normal equations
+//(X'X + lambda*I) w = X'y by Gauss-Jordan elimination, with an unpenalized
+//intercept term folded in as an extra, always-on column.
+func ridgeLinearFit(x [][]float64, y []float64, lambda float64) (weights []float64, bias float64) {
+	p := len(x[0]) + 1 //+1 for the intercept
+	xtx := make([][]float64, p)
+	xty := make([]float64, p)
+	for i := range xtx {
+		xtx[i] = make([]float64, p)
+	}
+
+	row := make([]float64, p)
+	for n := range x {
+		row[0] = 1
+		copy(row[1:], x[n])
+		for i := 0; i < p; i++ {
+			xty[i] += row[i] * y[n]
+			for j := 0; j < p; j++ {
+				xtx[i][j] += row[i] * row[j]
+			}
+		}
+	}
+	for i := 1; i < p; i++ {
+		xtx[i][i] += lambda
+	}
+
+	sol := solveLinearSystem(xtx, xty)
+	return sol[1:], sol[0]
+}
+
+//ridgeLogisticFit fits an L2-penalized logistic regression via a fixed
+//number of Newton (IRLS) iterations.
+func ridgeLogisticFit(x [][]float64, y []float64, lambda float64) (weights []float64, bias float64) {
+	p := len(x[0]) + 1
+	beta := make([]float64, p)
+
+	row := make([]float64, p)
+	for iter := 0; iter < 25; iter++ {
+		xtx := make([][]float64, p)
+		for i := range xtx {
+			xtx[i] = make([]float64, p)
+		}
+		xtz := make([]float64, p)
+
+		for n := range x {
+			row[0] = 1
+			copy(row[1:], x[n])
+
+			eta := 0.0
+			for i := 0; i < p; i++ {
+				eta += beta[i] * row[i]
+			}
+			mu := sigmoid(eta)
+			w := mu * (1 - mu)
+			if w < 1e-6 {
+				w = 1e-6
+			}
+			z := eta + (y[n]-mu)/w
+
+			for i := 0; i < p; i++ {
+				xtz[i] += w * row[i] * z
+				for j := 0; j < p; j++ {
+					xtx[i][j] += w * row[i] * row[j]
+				}
+			}
+		}
+		for i := 1; i < p; i++ {
+			xtx[i][i] += lambda
+		}
+		beta = solveLinearSystem(xtx, xtz)
+	}
+	return beta[1:], beta[0]
+}
+
+//solveLinearSystem solves a*w = b for w via Gauss-Jordan elimination with
+//partial pivoting. a and b are copied first and left unmodified.
+func solveLinearSystem(a [][]float64, b []float64) []float64 {
+	n := len(a)
+	aug := make([][]float64, n)
+	for i := range a {
+		aug[i] = append(append([]float64{}, a[i]...), b[i])
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if abs(aug[r][col]) > abs(aug[pivot][col]) {
+				pivot = r
+			}
+		}
+		aug[col], aug[pivot] = aug[pivot], aug[col]
+
+		if abs(aug[col][col]) < 1e-12 {
+			continue
+		}
+		pv := aug[col][col]
+		for c := col; c <= n; c++ {
+			aug[col][c] /= pv
+		}
+		for r := 0; r < n; r++ {
+			if r == col {
+				continue
+			}
+			factor := aug[r][col]
+			for c := col; c <= n; c++ {
+				aug[r][c] -= factor * aug[col][c]
+			}
+		}
+	}
+
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = aug[i][n]
+	}
+	return w
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,95 @@
+package CloudForest
+
+import "testing"
+
+func denseNumMatrix(names []string, cols [][]float64) *FeatureMatrix {
+	n := len(cols[0])
+	data := make([]Feature, len(cols))
+	m := make(map[string]int, len(cols))
+	for j, col := range cols {
+		data[j] = &DenseNumFeature{col, make([]bool, n), names[j], false}
+		m[names[j]] = j
+	}
+	return &FeatureMatrix{Data: data, Map: m, CaseLabels: make([]string, n)}
+}
+
+func TestGLMLeafRegressionRecoversLinearRelation(t *testing.T) {
+	x := []float64{0, 1, 2, 3, 4, 5, 6, 7}
+	y := make([]float64, len(x))
+	for i, v := range x {
+		y[i] = 2*v + 1
+	}
+	fm := denseNumMatrix([]string{"x"}, [][]float64{x})
+	target := &DenseNumFeature{y, make([]bool, len(y)), "y", false}
+
+	g := NewGLMLeaf([]string{"x"}, false, 1e-6)
+	cases := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	if err := g.Fit(fm, target, cases); err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	for _, i := range cases {
+		pred, err := g.Predict(fm, i)
+		if err != nil {
+			t.Fatalf("Predict: %v", err)
+		}
+		if diff := pred - y[i]; diff > 1e-3 || diff < -1e-3 {
+			t.Errorf("Predict(%v) = %v, want ~%v", i, pred, y[i])
+		}
+	}
+}
+
+func TestGLMLeafFallsBackToConstantWithTooFewCases(t *testing.T) {
+	x := []float64{0, 1, 2}
+	y := []float64{5, 7, 9}
+	fm := denseNumMatrix([]string{"x1", "x2", "x3"}, [][]float64{x, x, x})
+	target := &DenseNumFeature{y, make([]bool, len(y)), "y", false}
+
+	g := NewGLMLeaf([]string{"x1", "x2", "x3"}, false, 1e-6)
+	//3 cases, 3 features: Fit must fall back to a constant rather than
+	//try to estimate more parameters than there are cases.
+	if err := g.Fit(fm, target, []int{0, 1, 2}); err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	want := (5.0 + 7.0 + 9.0) / 3.0
+	for i := 0; i < 3; i++ {
+		pred, err := g.Predict(fm, i)
+		if err != nil {
+			t.Fatalf("Predict: %v", err)
+		}
+		if diff := pred - want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("Predict(%v) = %v, want constant %v", i, pred, want)
+		}
+	}
+}
+
+func TestGLMLeafFitErrorsOnMissingFeature(t *testing.T) {
+	fm := denseNumMatrix([]string{"x"}, [][]float64{{1, 2, 3, 4}})
+	target := &DenseNumFeature{[]float64{1, 2, 3, 4}, make([]bool, 4), "y", false}
+
+	g := NewGLMLeaf([]string{"x", "missing"}, false, 1e-6)
+	if err := g.Fit(fm, target, []int{0, 1, 2, 3}); err == nil {
+		t.Fatal("Fit should error when a FeatureNames entry is absent from fm")
+	}
+}
+
+func TestGLMLeafClassificationPredictsProbability(t *testing.T) {
+	x := []float64{-3, -2, -1, 1, 2, 3}
+	y := []float64{0, 0, 0, 1, 1, 1}
+	fm := denseNumMatrix([]string{"x"}, [][]float64{x})
+	target := &DenseCatFeature{&CatMap{make(map[string]int), make([]string, 0)}, []int{0, 0, 0, 1, 1, 1}, make([]bool, 6), "y", false, false}
+
+	g := NewGLMLeaf([]string{"x"}, true, 1e-6)
+	cases := []int{0, 1, 2, 3, 4, 5}
+	if err := g.Fit(fm, target, cases); err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	for i, want := range []bool{false, false, false, true, true, true} {
+		pred, err := g.Predict(fm, i)
+		if err != nil {
+			t.Fatalf("Predict: %v", err)
+		}
+		if (pred > 0.5) != want {
+			t.Errorf("Predict(%v) = %v, want on the %v side of 0.5", i, pred, want)
+		}
+	}
+}
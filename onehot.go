@@ -0,0 +1,101 @@
+package CloudForest
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+OneHotExpand returns k (or k-1 when dropFirst is set) binary
+DenseNumFeatures standing in for f, which itself is left unchanged, one per
+observed category, each named "f.Name=level" and
+holding 1 where a case has that category and 0 otherwise. The original
+feature's missing mask is copied onto every expanded column so that cases
+missing in f remain missing in all of its expansions.
+
+When dropFirst is true the first category (in sorted order) is omitted,
+which avoids the perfect collinearity a full one-hot expansion introduces
+in a linear or GLM leaf model.
+*/
+func (f *DenseCatFeature) OneHotExpand(dropFirst bool) []*DenseNumFeature {
+	codes := observedCodes(f)
+	levels := make([]string, len(codes))
+	codeForLevel := make(map[string]int, len(codes))
+	for i, code := range codes {
+		level := f.CatMap.NumToCat(code)
+		levels[i] = level
+		codeForLevel[level] = code
+	}
+	sort.Strings(levels)
+	if dropFirst && len(levels) > 0 {
+		levels = levels[1:]
+	}
+
+	expanded := make([]*DenseNumFeature, len(levels))
+	n := len(f.CatData)
+	for li, level := range levels {
+		code := codeForLevel[level]
+		data := make([]float64, n)
+		missing := make([]bool, n)
+		for i, c := range f.CatData {
+			missing[i] = f.Missing[i]
+			if !missing[i] && c == code {
+				data[i] = 1
+			}
+		}
+		expanded[li] = &DenseNumFeature{data, missing, fmt.Sprintf("%v=%v", f.Name, level), false}
+	}
+	return expanded
+}
+
+//observedCodes returns the distinct, non-missing category codes that
+//actually occur in f.CatData. DenseCatFeature.OneHotExpand and
+//OneHotExpandAll use this instead of walking f.CatMap directly, since a
+//CatMap can carry codes registered by ParseFeature/Append for levels that
+//never ended up assigned to a case.
+func observedCodes(f *DenseCatFeature) []int {
+	seen := make(map[int]bool)
+	for i, c := range f.CatData {
+		if f.Missing[i] {
+			continue
+		}
+		seen[c] = true
+	}
+	codes := make([]int, 0, len(seen))
+	for c := range seen {
+		codes = append(codes, c)
+	}
+	return codes
+}
+
+/*
+OneHotExpandAll replaces every categorical feature in fm whose cardinality
+is at most maxCardinality with its one-hot expansion (see
+DenseCatFeature.OneHotExpand), leaving higher cardinality categorical
+features and all numeric features untouched. It returns the names of every
+expanded binary column added, in the order they were appended, so that
+downstream code (e.g. GLMLeaf) can restrict itself to the expanded feature
+set.
+*/
+func (fm *FeatureMatrix) OneHotExpandAll(dropFirst bool, maxCardinality int) (expandedNames []string) {
+	kept := make([]Feature, 0, len(fm.Data))
+	for _, f := range fm.Data {
+		cf, ok := f.(*DenseCatFeature)
+		if !ok || len(observedCodes(cf)) > maxCardinality {
+			kept = append(kept, f)
+			continue
+		}
+		for _, nf := range cf.OneHotExpand(dropFirst) {
+			kept = append(kept, nf)
+			expandedNames = append(expandedNames, nf.Name)
+		}
+	}
+
+	newMap := make(map[string]int, len(kept))
+	for i, f := range kept {
+		newMap[f.GetName()] = i
+	}
+	fm.Data = kept
+	fm.Map = newMap
+	return
+}
@@ -0,0 +1,76 @@
+package CloudForest
+
+import "testing"
+
+func TestOneHotExpandDropsFirstSortedLevel(t *testing.T) {
+	m := &CatMap{make(map[string]int), make([]string, 0)}
+	f := &DenseCatFeature{m, nil, nil, "c", false, false}
+	f.CatData = []int{f.CatToNum("b"), f.CatToNum("a"), f.CatToNum("c")}
+	f.Missing = make([]bool, 3)
+
+	expanded := f.OneHotExpand(true)
+	if len(expanded) != 2 {
+		t.Fatalf("OneHotExpand(true) returned %v columns, want 2 (levels a,b,c minus first)", len(expanded))
+	}
+	for _, nf := range expanded {
+		if nf.Name == "c=a" {
+			t.Fatalf("OneHotExpand(true) kept dropped level %v, want \"a\" omitted", nf.Name)
+		}
+	}
+
+	full := f.OneHotExpand(false)
+	if len(full) != 3 {
+		t.Fatalf("OneHotExpand(false) returned %v columns, want 3", len(full))
+	}
+}
+
+func TestOneHotExpandCopiesMissingMask(t *testing.T) {
+	m := &CatMap{make(map[string]int), make([]string, 0)}
+	f := &DenseCatFeature{m, nil, nil, "c", false, false}
+	f.CatData = []int{f.CatToNum("a"), f.CatToNum("b"), f.CatToNum("a")}
+	f.Missing = []bool{false, true, false}
+
+	for _, nf := range f.OneHotExpand(false) {
+		if nf.Missing[1] != true {
+			t.Errorf("%v.Missing = %v, want case 1 missing copied through from the original feature", nf.Name, nf.Missing)
+		}
+		if nf.NumData[1] != 0 {
+			t.Errorf("%v.NumData[1] = %v, want 0 for a missing case regardless of its category code", nf.Name, nf.NumData[1])
+		}
+	}
+}
+
+func TestOneHotExpandAllSkipsHighCardinalityFeatures(t *testing.T) {
+	lowM := &CatMap{make(map[string]int), make([]string, 0)}
+	low := &DenseCatFeature{lowM, nil, nil, "low", false, false}
+	low.CatData = []int{low.CatToNum("a"), low.CatToNum("b")}
+	low.Missing = make([]bool, 2)
+
+	highM := &CatMap{make(map[string]int), make([]string, 0)}
+	high := &DenseCatFeature{highM, nil, nil, "high", false, false}
+	high.CatData = []int{high.CatToNum("a"), high.CatToNum("b"), high.CatToNum("c")}
+	high.Missing = make([]bool, 3)
+
+	x := &DenseNumFeature{[]float64{1, 2}, make([]bool, 2), "x", false}
+
+	fm := &FeatureMatrix{
+		Data:       []Feature{low, high, x},
+		Map:        map[string]int{"low": 0, "high": 1, "x": 2},
+		CaseLabels: []string{"r0", "r1"},
+	}
+
+	expandedNames := fm.OneHotExpandAll(false, 2)
+	if len(expandedNames) != 2 {
+		t.Fatalf("OneHotExpandAll expanded %v names, want 2 (low's two levels)", len(expandedNames))
+	}
+
+	if _, ok := fm.Map["high"]; !ok {
+		t.Error("high-cardinality feature \"high\" should be left untouched, but it's missing from fm.Map")
+	}
+	if _, ok := fm.Map["x"]; !ok {
+		t.Error("numeric feature \"x\" should be left untouched, but it's missing from fm.Map")
+	}
+	if _, ok := fm.Map["low"]; ok {
+		t.Error("expanded feature \"low\" should have been replaced by its one-hot columns")
+	}
+}
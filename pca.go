@@ -0,0 +1,215 @@
+package CloudForest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+/*
+PCAModel holds the fitted parameters of a truncated PCA projection, of the
+kind AddPCAFeatures fits, so that the identical transform can be re-applied
+to held-out data via TransformPCA.
+*/
+type PCAModel struct {
+	Columns  []string   //names of the numeric features the PCA was fit on, in column order
+	Means    []float64  //per-column mean used to center data, one per entry in Columns
+	Std      []float64  //per-column standard deviation used to scale data, nil if standardize was false
+	Loadings *mat.Dense //len(Columns) x K matrix of principal axes
+	K        int        //number of components retained
+}
+
+/*
+AddPCAFeatures fits a truncated, k component PCA over fm's numeric
+(DenseNumFeature) columns restricted to cases, imputing any missing values
+with the per-column mean and optionally z-score standardizing, then appends
+k new DenseNumFeatures named "PC:1".."PC:k" holding every case's projection
+onto the resulting components.
+
+AddPCAFeatures returns only the names of the new features; the PCAModel it
+fits is neither returned nor kept on fm, so its centering, scaling and
+rotation are not available to TransformPCA afterwards.
+*/
+func (fm *FeatureMatrix) AddPCAFeatures(k int, cases []int, standardize bool) ([]string, error) {
+	model, err := fm.fitPCA(k, cases, standardize)
+	if err != nil {
+		return nil, err
+	}
+	names, err := model.project(fm)
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+/*
+TransformPCA applies a previously fit PCAModel to fm2, appending the same
+k principal-component DenseNumFeatures computed with the model's stored
+means, standard deviations and loadings rather than refitting them. fm2 must
+contain every feature named in model.Columns.
+*/
+func (model *PCAModel) TransformPCA(fm2 *FeatureMatrix) ([]string, error) {
+	return model.project(fm2)
+}
+
+func (fm *FeatureMatrix) fitPCA(k int, cases []int, standardize bool) (*PCAModel, error) {
+	if k < 1 {
+		return nil, errors.New("pca: k must be >= 1")
+	}
+	if len(cases) < 2 {
+		return nil, fmt.Errorf("pca: need at least 2 cases to fit, got %v", len(cases))
+	}
+
+	columns := make([]string, 0, len(fm.Data))
+	numeric := make([]*DenseNumFeature, 0, len(fm.Data))
+	for _, f := range fm.Data {
+		if nf, ok := f.(*DenseNumFeature); ok {
+			columns = append(columns, nf.Name)
+			numeric = append(numeric, nf)
+		}
+	}
+	//a thin SVD of an n x p matrix only has min(n, p) columns in V, so k
+	//can't exceed either the number of numeric features or the number of
+	//cases the model is fit on.
+	if maxK := minInt(len(cases), len(columns)); k > maxK {
+		return nil, fmt.Errorf("pca: k=%v exceeds max of %v (min of %v cases and %v numeric features)", k, maxK, len(cases), len(columns))
+	}
+
+	means := make([]float64, len(numeric))
+	stds := make([]float64, len(numeric))
+	for j, nf := range numeric {
+		sum, n := 0.0, 0
+		for _, i := range cases {
+			if !nf.Missing[i] {
+				sum += nf.NumData[i]
+				n++
+			}
+		}
+		mean := 0.0
+		if n > 0 {
+			mean = sum / float64(n)
+		}
+		means[j] = mean
+
+		if standardize {
+			ss, n2 := 0.0, 0
+			for _, i := range cases {
+				v := nf.NumData[i]
+				if nf.Missing[i] {
+					v = mean
+				}
+				ss += (v - mean) * (v - mean)
+				n2++
+			}
+			std := 1.0
+			if n2 > 1 && ss > 0 {
+				std = math.Sqrt(ss / float64(n2-1))
+			}
+			stds[j] = std
+		}
+	}
+
+	x := mat.NewDense(len(cases), len(numeric), nil)
+	for r, i := range cases {
+		for j, nf := range numeric {
+			v := nf.NumData[i]
+			if nf.Missing[i] {
+				v = means[j]
+			}
+			v -= means[j]
+			if standardize {
+				v /= stds[j]
+			}
+			x.Set(r, j, v)
+		}
+	}
+
+	var svd mat.SVD
+	if ok := svd.Factorize(x, mat.SVDThin); !ok {
+		return nil, errors.New("pca: SVD factorization failed")
+	}
+	var v mat.Dense
+	svd.VTo(&v)
+
+	loadings := mat.NewDense(len(numeric), k, nil)
+	loadings.Copy(v.Slice(0, len(numeric), 0, k))
+
+	model := &PCAModel{
+		Columns:  columns,
+		Means:    means,
+		K:        k,
+		Loadings: loadings,
+	}
+	if standardize {
+		model.Std = stds
+	}
+	return model, nil
+}
+
+//project computes the model's components for every case in fm2 and appends
+//them as new DenseNumFeatures, returning their names.
+func (model *PCAModel) project(fm2 *FeatureMatrix) ([]string, error) {
+	cols := make([]*DenseNumFeature, len(model.Columns))
+	for j, name := range model.Columns {
+		idx, ok := fm2.Map[name]
+		if !ok {
+			return nil, fmt.Errorf("pca: feature %q used to fit the model is not present", name)
+		}
+		nf, ok := fm2.Data[idx].(*DenseNumFeature)
+		if !ok {
+			return nil, fmt.Errorf("pca: feature %q is not numeric", name)
+		}
+		cols[j] = nf
+	}
+
+	ncases := len(fm2.CaseLabels)
+	row := make([]float64, len(cols))
+	names := make([]string, model.K)
+	pcs := make([][]float64, model.K)
+	for c := range pcs {
+		pcs[c] = make([]float64, ncases)
+	}
+
+	for i := 0; i < ncases; i++ {
+		for j, nf := range cols {
+			v := nf.NumData[i]
+			if nf.Missing[i] {
+				v = model.Means[j]
+			}
+			v -= model.Means[j]
+			if model.Std != nil {
+				v /= model.Std[j]
+			}
+			row[j] = v
+		}
+		for c := 0; c < model.K; c++ {
+			sum := 0.0
+			for j := range row {
+				sum += row[j] * model.Loadings.At(j, c)
+			}
+			pcs[c][i] = sum
+		}
+	}
+
+	for c := 0; c < model.K; c++ {
+		name := fmt.Sprintf("PC:%v", c+1)
+		names[c] = name
+		fm2.Map[name] = len(fm2.Data)
+		fm2.Data = append(fm2.Data, &DenseNumFeature{
+			pcs[c],
+			make([]bool, ncases),
+			name,
+			false,
+		})
+	}
+	return names, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
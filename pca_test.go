@@ -0,0 +1,91 @@
+package CloudForest
+
+import "testing"
+
+func TestAddPCAFeaturesRecoversDominantAxis(t *testing.T) {
+	//y is just x*2 plus noise-free scaling, so a single component should
+	//capture essentially all of the variance and the projection should be
+	//proportional to either column.
+	x := &DenseNumFeature{[]float64{1, 2, 3, 4, 5, 6}, make([]bool, 6), "x", false}
+	y := &DenseNumFeature{[]float64{2, 4, 6, 8, 10, 12}, make([]bool, 6), "y", false}
+	fm := &FeatureMatrix{
+		Data:       []Feature{x, y},
+		Map:        map[string]int{"x": 0, "y": 1},
+		CaseLabels: []string{"a", "b", "c", "d", "e", "f"},
+	}
+
+	cases := []int{0, 1, 2, 3, 4, 5}
+	names, err := fm.AddPCAFeatures(1, cases, true)
+	if err != nil {
+		t.Fatalf("AddPCAFeatures: %v", err)
+	}
+	if len(names) != 1 || names[0] != "PC:1" {
+		t.Fatalf("names = %v, want [PC:1]", names)
+	}
+
+	pc, ok := fm.Data[fm.Map["PC:1"]].(*DenseNumFeature)
+	if !ok {
+		t.Fatalf("PC:1 is not a *DenseNumFeature")
+	}
+	//x and y are perfectly correlated, so PC:1 must be an affine function
+	//of x alone: the ratio of differences between any two cases should
+	//match the ratio of the corresponding x differences, regardless of the
+	//SVD's arbitrary sign/scale convention.
+	wantRatio := (pc.NumData[1] - pc.NumData[0]) / (x.NumData[1] - x.NumData[0])
+	for i := 2; i < len(pc.NumData); i++ {
+		gotRatio := (pc.NumData[i] - pc.NumData[0]) / (x.NumData[i] - x.NumData[0])
+		if diff := gotRatio - wantRatio; diff > 1e-6 || diff < -1e-6 {
+			t.Errorf("PC:1 ratio at case %v = %v, want ~%v (x and y are collinear)", i, gotRatio, wantRatio)
+		}
+	}
+}
+
+func TestAddPCAFeaturesRejectsTooLargeK(t *testing.T) {
+	x := &DenseNumFeature{[]float64{1, 2, 3}, make([]bool, 3), "x", false}
+	fm := &FeatureMatrix{
+		Data:       []Feature{x},
+		Map:        map[string]int{"x": 0},
+		CaseLabels: []string{"a", "b", "c"},
+	}
+	if _, err := fm.AddPCAFeatures(2, []int{0, 1, 2}, false); err == nil {
+		t.Fatal("AddPCAFeatures with k exceeding the numeric feature count should error")
+	}
+}
+
+func TestTransformPCAReappliesFittedModel(t *testing.T) {
+	x := &DenseNumFeature{[]float64{1, 2, 3, 4}, make([]bool, 4), "x", false}
+	y := &DenseNumFeature{[]float64{4, 3, 2, 1}, make([]bool, 4), "y", false}
+	fm := &FeatureMatrix{
+		Data:       []Feature{x, y},
+		Map:        map[string]int{"x": 0, "y": 1},
+		CaseLabels: []string{"a", "b", "c", "d"},
+	}
+	model, err := fm.fitPCA(1, []int{0, 1, 2, 3}, true)
+	if err != nil {
+		t.Fatalf("fitPCA: %v", err)
+	}
+	//fitPCA only fits; AddPCAFeatures refits the same model and appends PC:1
+	//to fm, giving the projection TransformPCA must reproduce on fm2.
+	if _, err := fm.AddPCAFeatures(1, []int{0, 1, 2, 3}, true); err != nil {
+		t.Fatalf("AddPCAFeatures: %v", err)
+	}
+
+	x2 := &DenseNumFeature{[]float64{1, 2, 3, 4}, make([]bool, 4), "x", false}
+	y2 := &DenseNumFeature{[]float64{4, 3, 2, 1}, make([]bool, 4), "y", false}
+	fm2 := &FeatureMatrix{
+		Data:       []Feature{x2, y2},
+		Map:        map[string]int{"x": 0, "y": 1},
+		CaseLabels: []string{"a", "b", "c", "d"},
+	}
+	if _, err := model.TransformPCA(fm2); err != nil {
+		t.Fatalf("TransformPCA: %v", err)
+	}
+
+	got := fm2.Data[fm2.Map["PC:1"]].(*DenseNumFeature).NumData
+	want := fm.Data[fm.Map["PC:1"]].(*DenseNumFeature).NumData
+	for i := range want {
+		if diff := got[i] - want[i]; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("TransformPCA[%v] = %v, want %v (same data re-projected with the fitted model)", i, got[i], want[i])
+		}
+	}
+}
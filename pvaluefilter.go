@@ -0,0 +1,476 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+/*
+FilterByPValue screens every feature in fm for a statistical association with
+target and removes those that don't pass. A feature is dropped if its
+p-value exceeds pmax or if its non-missing minor-category frequency (the
+fraction of non-missing cases that fall into its smallest category, or
+smallest quantile bin for numeric features) is below minFrequency.
+
+The test used depends on the types involved:
+
+  - categorical feature vs categorical target: Pearson's chi-square test on
+    the contingency table, with Yates' continuity correction applied when
+    the table is 2x2.
+
+  - categorical feature vs numeric target: one-way ANOVA F-test across the
+    feature's categories.
+
+  - numeric feature vs either target type: the feature is first discretized
+    into quantile bins and the corresponding test above is applied to the
+    bins.
+
+FilterByPValue returns the names of the dropped features so callers can log
+what was removed. It is intended as a fast pre-screen for wide data sets,
+trimming candidate features before a forest is grown. It returns an error,
+leaving fm untouched, if target is not one of the concrete types this
+package knows how to test against.
+*/
+func (fm *FeatureMatrix) FilterByPValue(target Target, pmax float64, minFrequency float64) (dropped []string, err error) {
+	pvalues, minorFreq, err := fm.pValues(target)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]Feature, 0, len(fm.Data))
+	newMap := make(map[string]int, len(fm.Data))
+	for i, f := range fm.Data {
+		name := f.GetName()
+		if pvalues[i] > pmax || minorFreq[i] < minFrequency {
+			dropped = append(dropped, name)
+			continue
+		}
+		newMap[name] = len(kept)
+		kept = append(kept, f)
+	}
+	fm.Data = kept
+	fm.Map = newMap
+	return
+}
+
+/*
+PValues reports, for every feature currently in fm, the p-value of its
+association test against target (see FilterByPValue for the tests used)
+along with the feature's minor-category frequency. Unlike FilterByPValue it
+does not mutate fm, which makes it useful for seeding BestSplitter's
+candidate list with only the most significant features without discarding
+the rest of the matrix. It returns an error if target is not one of the
+concrete types this package knows how to test against.
+*/
+func (fm *FeatureMatrix) PValues(target Target) (pvalues []float64, minorFrequency []float64, err error) {
+	return fm.pValues(target)
+}
+
+//pValueBins is the number of quantile bins a numeric feature is discretized
+//into before a chi-square or ANOVA test is run against it. A numeric target
+//is not binned; ANOVA uses its raw values.
+const pValueBins = 4
+
+func (fm *FeatureMatrix) pValues(target Target) (pvalues []float64, minorFreq []float64, err error) {
+	targetCodes, targetValues, targetIsCat, err := codesOrValues(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := len(fm.Data)
+	pvalues = make([]float64, n)
+	minorFreq = make([]float64, n)
+
+	for i, f := range fm.Data {
+		codes, freq := codesOrValuesForFeature(f)
+		minorFreq[i] = freq
+		if targetIsCat {
+			pvalues[i] = chiSquarePValue(codes, targetCodes)
+		} else {
+			pvalues[i] = anovaPValue(codes, targetValues)
+		}
+	}
+	return
+}
+
+//codesOrValuesForFeature returns integer group codes (-1 for missing) for a
+//feature, binning numeric features into pValueBins quantile buckets, along
+//with the minor-category (or minor-bin) frequency among non-missing cases.
+func codesOrValuesForFeature(f Feature) (codes []int, minorFreq float64) {
+	switch ft := f.(type) {
+	case *DenseCatFeature:
+		return categoryCodes(ft.CatData, ft.Missing)
+	case *DenseNumFeature:
+		return quantileBinCodes(ft.NumData, ft.Missing, pValueBins)
+	case *SparseCatFeature:
+		dense := ft.toDense()
+		return categoryCodes(dense.CatData, dense.Missing)
+	case *SparseNumFeature:
+		dense := ft.toDense()
+		return quantileBinCodes(dense.NumData, dense.Missing, pValueBins)
+	default:
+		return nil, 0
+	}
+}
+
+//codesOrValues extracts both a categorical coding and a raw numeric value
+//slice for target (whichever is meaningful is picked by the caller based on
+//targetIsCat), since the same target is reused for every feature's test. It
+//errors if target isn't one of the concrete types pValues knows how to test
+//against, rather than silently treating it as categorical with no codes.
+func codesOrValues(target Target) (codes []int, values []float64, isCat bool, err error) {
+	switch tf := target.(type) {
+	case *DenseCatFeature:
+		codes, _ = categoryCodes(tf.CatData, tf.Missing)
+		return codes, nil, true, nil
+	case *DenseNumFeature:
+		return nil, maskedValues(tf.NumData, tf.Missing), false, nil
+	case *SparseCatFeature:
+		dense := tf.toDense()
+		codes, _ = categoryCodes(dense.CatData, dense.Missing)
+		return codes, nil, true, nil
+	case *SparseNumFeature:
+		dense := tf.toDense()
+		return nil, maskedValues(dense.NumData, dense.Missing), false, nil
+	default:
+		return nil, nil, false, fmt.Errorf("pvaluefilter: unrecognized target type %T, can't test for association", target)
+	}
+}
+
+func maskedValues(data []float64, missing []bool) []float64 {
+	values := make([]float64, len(data))
+	for i, v := range data {
+		if missing[i] {
+			values[i] = math.NaN()
+			continue
+		}
+		values[i] = v
+	}
+	return values
+}
+
+func categoryCodes(data []int, missing []bool) (codes []int, minorFreq float64) {
+	codes = make([]int, len(data))
+	counts := make(map[int]int)
+	nonMissing := 0
+	for i, v := range data {
+		if missing[i] {
+			codes[i] = -1
+			continue
+		}
+		codes[i] = v
+		counts[v]++
+		nonMissing++
+	}
+	minorFreq = minorFrequency(counts, nonMissing)
+	return
+}
+
+func quantileBinCodes(data []float64, missing []bool, nbins int) (codes []int, minorFreq float64) {
+	sorted := make([]float64, 0, len(data))
+	for i, v := range data {
+		if !missing[i] {
+			sorted = append(sorted, v)
+		}
+	}
+	sort.Float64s(sorted)
+
+	breaks := make([]float64, nbins-1)
+	for i := range breaks {
+		pos := float64(len(sorted)) * float64(i+1) / float64(nbins)
+		idx := int(pos)
+		if idx >= len(sorted) {
+			idx = len(sorted) - 1
+		}
+		breaks[i] = sorted[idx]
+	}
+
+	codes = make([]int, len(data))
+	counts := make(map[int]int)
+	nonMissing := 0
+	for i, v := range data {
+		if missing[i] {
+			codes[i] = -1
+			continue
+		}
+		bin := 0
+		for bin < len(breaks) && v > breaks[bin] {
+			bin++
+		}
+		codes[i] = bin
+		counts[bin]++
+		nonMissing++
+	}
+	minorFreq = minorFrequency(counts, nonMissing)
+	return
+}
+
+func minorFrequency(counts map[int]int, nonMissing int) float64 {
+	if nonMissing == 0 || len(counts) == 0 {
+		return 0
+	}
+	min := nonMissing
+	for _, c := range counts {
+		if c < min {
+			min = c
+		}
+	}
+	return float64(min) / float64(nonMissing)
+}
+
+//chiSquarePValue runs a Pearson chi-square test of independence between two
+//sets of categorical codes (-1 marks a missing/excluded case), applying
+//Yates' continuity correction when the table is 2x2.
+func chiSquarePValue(a, b []int) float64 {
+	rowOf := make(map[int]int)
+	colOf := make(map[int]int)
+	type cell struct{ r, c int }
+	table := make(map[cell]int)
+	rowTotals := make(map[int]int)
+	colTotals := make(map[int]int)
+	total := 0
+
+	for i := range a {
+		if i >= len(b) || a[i] < 0 || b[i] < 0 {
+			continue
+		}
+		r, ok := rowOf[a[i]]
+		if !ok {
+			r = len(rowOf)
+			rowOf[a[i]] = r
+		}
+		c, ok := colOf[b[i]]
+		if !ok {
+			c = len(colOf)
+			colOf[b[i]] = c
+		}
+		table[cell{r, c}]++
+		rowTotals[r]++
+		colTotals[c]++
+		total++
+	}
+
+	nr, nc := len(rowOf), len(colOf)
+	if nr < 2 || nc < 2 || total == 0 {
+		return 1
+	}
+
+	correct := nr == 2 && nc == 2
+	stat := 0.0
+	for r := 0; r < nr; r++ {
+		for c := 0; c < nc; c++ {
+			expected := float64(rowTotals[r]) * float64(colTotals[c]) / float64(total)
+			if expected == 0 {
+				continue
+			}
+			observed := float64(table[cell{r, c}])
+			diff := math.Abs(observed - expected)
+			if correct {
+				diff -= 0.5
+				if diff < 0 {
+					diff = 0
+				}
+			}
+			stat += diff * diff / expected
+		}
+	}
+
+	df := float64((nr - 1) * (nc - 1))
+	return chiSquareSurvival(stat, df)
+}
+
+//anovaPValue runs a one-way ANOVA F-test of values grouped by codes (-1
+//marks a missing/excluded case).
+func anovaPValue(codes []int, values []float64) float64 {
+	sums := make(map[int]float64)
+	counts := make(map[int]int)
+	grandSum, grandN := 0.0, 0
+
+	for i, g := range codes {
+		if g < 0 || i >= len(values) || math.IsNaN(values[i]) {
+			continue
+		}
+		sums[g] += values[i]
+		counts[g]++
+		grandSum += values[i]
+		grandN++
+	}
+
+	k := len(counts)
+	if k < 2 || grandN <= k {
+		return 1
+	}
+	grandMean := grandSum / float64(grandN)
+
+	ssBetween := 0.0
+	for g, sum := range sums {
+		mean := sum / float64(counts[g])
+		ssBetween += float64(counts[g]) * (mean - grandMean) * (mean - grandMean)
+	}
+
+	ssWithin := 0.0
+	for i, g := range codes {
+		if g < 0 || i >= len(values) || math.IsNaN(values[i]) {
+			continue
+		}
+		mean := sums[g] / float64(counts[g])
+		ssWithin += (values[i] - mean) * (values[i] - mean)
+	}
+
+	dfBetween := float64(k - 1)
+	dfWithin := float64(grandN - k)
+	if ssWithin == 0 || dfWithin <= 0 {
+		return 0
+	}
+
+	f := (ssBetween / dfBetween) / (ssWithin / dfWithin)
+	if f <= 0 {
+		return 1
+	}
+	return fDistSurvival(f, dfBetween, dfWithin)
+}
+
+//chiSquareSurvival returns P(X > stat) for a chi-square distribution with df
+//degrees of freedom, computed via the upper regularized incomplete gamma
+//function.
+func chiSquareSurvival(stat, df float64) float64 {
+	if stat <= 0 || df <= 0 {
+		return 1
+	}
+	return upperIncompleteGammaReg(df/2, stat/2)
+}
+
+//fDistSurvival returns P(X > f) for an F-distribution with d1, d2 degrees of
+//freedom, computed via the regularized incomplete beta function.
+func fDistSurvival(f, d1, d2 float64) float64 {
+	x := d2 / (d2 + d1*f)
+	return regularizedIncompleteBeta(d2/2, d1/2, x)
+}
+
+//upperIncompleteGammaReg computes Q(a,x), the upper regularized incomplete
+//gamma function, using a series expansion for x<a+1 and a continued
+//fraction otherwise (Numerical Recipes §6.2).
+func upperIncompleteGammaReg(a, x float64) float64 {
+	if x < 0 || a <= 0 {
+		return 1
+	}
+	if x == 0 {
+		return 1
+	}
+	if x < a+1 {
+		return 1 - lowerGammaSeries(a, x)
+	}
+	return gammaContinuedFraction(a, x)
+}
+
+func lowerGammaSeries(a, x float64) float64 {
+	gln, _ := math.Lgamma(a)
+	ap := a
+	sum := 1 / a
+	del := sum
+	for n := 0; n < 200; n++ {
+		ap++
+		del *= x / ap
+		sum += del
+		if math.Abs(del) < math.Abs(sum)*1e-12 {
+			break
+		}
+	}
+	return sum * math.Exp(-x+a*math.Log(x)-gln)
+}
+
+func gammaContinuedFraction(a, x float64) float64 {
+	gln, _ := math.Lgamma(a)
+	const fpmin = 1e-300
+	b := x + 1 - a
+	c := 1 / fpmin
+	d := 1 / b
+	h := d
+	for i := 1; i < 200; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < fpmin {
+			d = fpmin
+		}
+		c = b + an/c
+		if math.Abs(c) < fpmin {
+			c = fpmin
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-12 {
+			break
+		}
+	}
+	return math.Exp(-x+a*math.Log(x)-gln) * h
+}
+
+//regularizedIncompleteBeta computes I_x(a,b) using the continued fraction
+//representation (Numerical Recipes §6.4).
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lbeta, _ := math.Lgamma(a)
+	lbeta2, _ := math.Lgamma(b)
+	lbetaAB, _ := math.Lgamma(a + b)
+	bt := math.Exp(lbetaAB - lbeta - lbeta2 + a*math.Log(x) + b*math.Log(1-x))
+
+	if x < (a+1)/(a+b+2) {
+		return bt * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - bt*betaContinuedFraction(b, a, 1-x)/b
+}
+
+func betaContinuedFraction(a, b, x float64) float64 {
+	const fpmin = 1e-300
+	qab := a + b
+	qap := a + 1
+	qam := a - 1
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < fpmin {
+		d = fpmin
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m < 200; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < fpmin {
+			d = fpmin
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < fpmin {
+			c = fpmin
+		}
+		d = 1 / d
+		h *= d * c
+
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < fpmin {
+			d = fpmin
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < fpmin {
+			c = fpmin
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-12 {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,123 @@
+package CloudForest
+
+import "testing"
+
+func TestChiSquareSurvival(t *testing.T) {
+	cases := []struct {
+		stat, df, want float64
+	}{
+		{3.841459, 1, 0.05},
+		{5.991465, 2, 0.05},
+		{0, 5, 1},
+	}
+	for _, c := range cases {
+		got := chiSquareSurvival(c.stat, c.df)
+		if diff := got - c.want; diff > 1e-3 || diff < -1e-3 {
+			t.Errorf("chiSquareSurvival(%v, %v) = %v, want ~%v", c.stat, c.df, got, c.want)
+		}
+	}
+}
+
+func TestFDistSurvival(t *testing.T) {
+	//3.325835 is the standard 0.05 critical value for an F(5,10) distribution.
+	got := fDistSurvival(3.325835, 5, 10)
+	if diff := got - 0.05; diff > 1e-3 || diff < -1e-3 {
+		t.Errorf("fDistSurvival(3.325835, 5, 10) = %v, want ~0.05", got)
+	}
+	if got := fDistSurvival(0, 5, 10); got != 1 {
+		t.Errorf("fDistSurvival(0, 5, 10) = %v, want 1", got)
+	}
+}
+
+func TestMinorFrequency(t *testing.T) {
+	got := minorFrequency(map[int]int{0: 3, 1: 7}, 10)
+	if want := 0.3; got != want {
+		t.Errorf("minorFrequency = %v, want %v", got, want)
+	}
+	if got := minorFrequency(nil, 0); got != 0 {
+		t.Errorf("minorFrequency(nil, 0) = %v, want 0", got)
+	}
+}
+
+func TestCategoryCodes(t *testing.T) {
+	codes, freq := categoryCodes([]int{0, 1, 0, 2}, []bool{false, false, false, true})
+	want := []int{0, 1, 0, -1}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("categoryCodes = %v, want %v", codes, want)
+		}
+	}
+	if wantFreq := 1.0 / 3; freq != wantFreq {
+		t.Errorf("categoryCodes minorFreq = %v, want %v", freq, wantFreq)
+	}
+}
+
+func TestAnovaPValuePerfectSeparation(t *testing.T) {
+	//zero within-group variance is a degenerate case handled explicitly,
+	//not via the F distribution.
+	got := anovaPValue([]int{0, 0, 0, 1, 1, 1}, []float64{1, 1, 1, 10, 10, 10})
+	if got != 0 {
+		t.Errorf("anovaPValue with zero within-group variance = %v, want 0", got)
+	}
+}
+
+func TestAnovaPValueNoSeparation(t *testing.T) {
+	//identical group means (zero between-group variance) must not reject.
+	got := anovaPValue([]int{0, 0, 1, 1}, []float64{1, 2, 1, 2})
+	if got != 1 {
+		t.Errorf("anovaPValue with zero between-group variance = %v, want 1", got)
+	}
+}
+
+func TestChiSquarePValueIndependence(t *testing.T) {
+	//a perfectly balanced 2x2 table has zero chi-square statistic.
+	a := []int{0, 0, 0, 0, 1, 1, 1, 1}
+	b := []int{0, 0, 1, 1, 0, 0, 1, 1}
+	if got := chiSquarePValue(a, b); got != 1 {
+		t.Errorf("chiSquarePValue on a balanced table = %v, want 1", got)
+	}
+}
+
+//stubTarget is a Target implementation pValues doesn't know how to extract
+//codes/values from, used to exercise the unrecognized-type error path.
+type stubTarget struct{}
+
+func (stubTarget) Impurity(cases *[]int, counter *[]int) float64 { return 0 }
+func (stubTarget) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) float64 {
+	return 0
+}
+
+func TestPValuesErrorsOnUnrecognizedTargetType(t *testing.T) {
+	fm := &FeatureMatrix{
+		Data: []Feature{&DenseNumFeature{[]float64{1, 2, 3}, make([]bool, 3), "x", false}},
+		Map:  map[string]int{"x": 0},
+	}
+	if _, _, err := fm.PValues(stubTarget{}); err == nil {
+		t.Fatal("PValues should error on a target type it can't extract codes/values from")
+	}
+}
+
+func TestFilterByPValueErrorsOnUnrecognizedTargetTypeAndLeavesFmUntouched(t *testing.T) {
+	x := &DenseNumFeature{[]float64{1, 2, 3}, make([]bool, 3), "x", false}
+	fm := &FeatureMatrix{
+		Data: []Feature{x},
+		Map:  map[string]int{"x": 0},
+	}
+	if _, err := fm.FilterByPValue(stubTarget{}, 0.05, 0); err == nil {
+		t.Fatal("FilterByPValue should error on a target type it can't extract codes/values from")
+	}
+	if len(fm.Data) != 1 || fm.Data[0] != x {
+		t.Errorf("FilterByPValue should leave fm.Data untouched on error, got %v", fm.Data)
+	}
+}
+
+func TestChiSquarePValueAssociation(t *testing.T) {
+	//perfect association between a and b should be strongly significant.
+	//use enough cases that Yates' correction (applied on this 2x2 table)
+	//can't pull the statistic back down near the threshold.
+	a := []int{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1}
+	b := []int{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1}
+	if got := chiSquarePValue(a, b); got > 0.01 {
+		t.Errorf("chiSquarePValue on a perfectly associated table = %v, want < 0.01", got)
+	}
+}
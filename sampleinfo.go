@@ -0,0 +1,198 @@
+package CloudForest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"strings"
+)
+
+/*
+SampleInfoOpts configures how LoadSampleInfo interprets a samples file's
+columns. CaseColumn defaults to the file's first column when left blank;
+TrainColumn, CaseControlColumn and StrataColumn are all optional and are
+simply left unpopulated on the returned SampleInfo when blank.
+*/
+type SampleInfoOpts struct {
+	CaseColumn        string  //column holding the case label, matched against FeatureMatrix.CaseLabels
+	TrainColumn       string  //column flagging training-set membership (e.g. "1"/"0", "train"/"test"); blank cells are filled in randomly
+	CaseControlColumn string  //column holding a case/control (or class) label
+	StrataColumn      string  //column holding an optional stratification group used only for logging/grouping
+	TrainFraction     float64 //fraction of cases placed in the training set when TrainColumn is absent or blank for a case
+	Seed              int64   //seed for the RNG used to fill in missing training flags
+}
+
+/*
+SampleInfo records, per case in a FeatureMatrix, whether the case belongs to
+the training set, its case/control (or class) label and its optional
+stratification group, as loaded by LoadSampleInfo. CaseLabels[i],
+Train[i], CaseControl[i] and Strata[i] all refer to the same case.
+*/
+type SampleInfo struct {
+	CaseLabels  []string
+	Train       []bool
+	CaseControl []string
+	Strata      []string
+}
+
+/*
+LoadSampleInfo reads a CSV/TSV samples file keyed by case label (matching
+fm.CaseLabels) and returns a SampleInfo populated with each case's
+training-set membership, case/control label and stratification group as
+specified by opts.
+
+Cases whose training flag is blank or whose column is altogether absent are
+randomly assigned using opts.TrainFraction and opts.Seed. The random fill is
+done separately within each CaseControlColumn group (or across all cases if
+no such column is given) so that the resulting split preserves case/control
+balance. Case labels present in the samples file but absent from fm are
+logged and skipped; cases absent from the file are left with Train=false
+and must be filled in by the random pass.
+*/
+func (fm *FeatureMatrix) LoadSampleInfo(r io.Reader, opts SampleInfoOpts) (*SampleInfo, error) {
+	tsv := csv.NewReader(r)
+	tsv.Comma = ','
+	tsv.FieldsPerRecord = -1
+
+	header, err := tsv.Read()
+	if err != nil {
+		return nil, fmt.Errorf("samples: reading header: %v", err)
+	}
+	if len(header) > 0 && strings.Contains(header[0], "\t") {
+		//re-split as tsv if the reader was handed tab separated data
+		tsv.Comma = '\t'
+		header = strings.Split(header[0], "\t")
+	}
+
+	col := func(name string) int {
+		if name == "" {
+			return -1
+		}
+		for i, h := range header {
+			if h == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	caseCol := 0
+	if opts.CaseColumn != "" {
+		caseCol = col(opts.CaseColumn)
+		if caseCol == -1 {
+			return nil, fmt.Errorf("samples: case column %q not found", opts.CaseColumn)
+		}
+	}
+	trainCol := col(opts.TrainColumn)
+	ccCol := col(opts.CaseControlColumn)
+	strataCol := col(opts.StrataColumn)
+
+	n := len(fm.CaseLabels)
+	caseIndex := make(map[string]int, n)
+	for i, label := range fm.CaseLabels {
+		caseIndex[label] = i
+	}
+
+	si := &SampleInfo{
+		CaseLabels:  append([]string{}, fm.CaseLabels...),
+		Train:       make([]bool, n),
+		CaseControl: make([]string, n),
+		Strata:      make([]string, n),
+	}
+	trainSpecified := make([]bool, n)
+
+	for {
+		record, err := tsv.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			log.Print("Error:", err)
+			break
+		}
+
+		if caseCol >= len(record) {
+			log.Printf("samples: row %q has no case column, skipping", strings.Join(record, ","))
+			continue
+		}
+		label := record[caseCol]
+		i, ok := caseIndex[label]
+		if !ok {
+			log.Printf("samples: case %q not present in feature matrix, skipping", label)
+			continue
+		}
+
+		if trainCol != -1 && trainCol < len(record) && record[trainCol] != "" {
+			si.Train[i] = parseTrainFlag(record[trainCol])
+			trainSpecified[i] = true
+		}
+		if ccCol != -1 && ccCol < len(record) {
+			si.CaseControl[i] = record[ccCol]
+		}
+		if strataCol != -1 && strataCol < len(record) {
+			si.Strata[i] = record[strataCol]
+		}
+	}
+
+	si.fillMissingTrainFlags(trainSpecified, opts.TrainFraction, opts.Seed)
+	return si, nil
+}
+
+func parseTrainFlag(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "t", "true", "train", "yes", "y":
+		return true
+	default:
+		return false
+	}
+}
+
+//fillMissingTrainFlags randomly assigns Train for every case whose flag
+//wasn't specified in the samples file, keeping the case/control groups
+//(as given by CaseControl, or all cases together if it's unused) at
+//fraction close to TrainFraction.
+func (si *SampleInfo) fillMissingTrainFlags(specified []bool, fraction float64, seed int64) {
+	groups := make(map[string][]int)
+	for i, spec := range specified {
+		if spec {
+			continue
+		}
+		groups[si.CaseControl[i]] = append(groups[si.CaseControl[i]], i)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	for _, cases := range groups {
+		rng.Shuffle(len(cases), func(i, j int) { cases[i], cases[j] = cases[j], cases[i] })
+		nTrain := int(fraction*float64(len(cases)) + 0.5)
+		for k, i := range cases {
+			si.Train[i] = k < nTrain
+		}
+	}
+}
+
+/*
+TrainOOBCases splits si's cases into training and out-of-bag (held out)
+index slices, suitable for use as the cases/oob arguments to
+FeatureMatrix.BestSplitter.
+
+BLOCKED: this request asked for a -samples CLI flag that replaces
+growforest's current ad-hoc target-column and holdout-fraction flags,
+wired so LoadSampleInfo/TrainOOBCases drive the training loop's cases/oob
+split. growforest's flag parsing and top-level training loop are not part
+of this source tree, so that wiring could not be written or tested here;
+as shipped, there is no -samples flag and no caller reaches
+TrainOOBCases, so the request is not usable end-to-end as specified.
+TrainOOBCases is the seam such a flag's handler would call into: pass its
+train/oob results straight through as BestSplitter's cases/oob arguments.
+*/
+func (si *SampleInfo) TrainOOBCases() (train []int, oob []int) {
+	for i, isTrain := range si.Train {
+		if isTrain {
+			train = append(train, i)
+		} else {
+			oob = append(oob, i)
+		}
+	}
+	return
+}
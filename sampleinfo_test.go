@@ -0,0 +1,68 @@
+package CloudForest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadSampleInfoParsesExplicitColumns(t *testing.T) {
+	fm := &FeatureMatrix{CaseLabels: []string{"s1", "s2", "s3"}}
+	csv := "id,train,group\ns1,1,case\ns2,0,control\ns3,yes,case\n"
+
+	si, err := fm.LoadSampleInfo(strings.NewReader(csv), SampleInfoOpts{
+		CaseColumn:        "id",
+		TrainColumn:       "train",
+		CaseControlColumn: "group",
+	})
+	if err != nil {
+		t.Fatalf("LoadSampleInfo: %v", err)
+	}
+	want := []bool{true, false, true}
+	for i, w := range want {
+		if si.Train[i] != w {
+			t.Errorf("Train[%v] = %v, want %v", i, si.Train[i], w)
+		}
+	}
+	if si.CaseControl[1] != "control" {
+		t.Errorf("CaseControl[1] = %v, want control", si.CaseControl[1])
+	}
+}
+
+func TestLoadSampleInfoFillsMissingTrainFlags(t *testing.T) {
+	fm := &FeatureMatrix{CaseLabels: []string{"s1", "s2", "s3", "s4"}}
+	csv := "id,train\ns1,1\ns2,\ns3,\ns4,0\n"
+
+	si, err := fm.LoadSampleInfo(strings.NewReader(csv), SampleInfoOpts{
+		CaseColumn:    "id",
+		TrainColumn:   "train",
+		TrainFraction: 0.5,
+		Seed:          42,
+	})
+	if err != nil {
+		t.Fatalf("LoadSampleInfo: %v", err)
+	}
+	if !si.Train[0] || si.Train[3] {
+		t.Fatalf("explicit flags should be preserved, got Train = %v", si.Train)
+	}
+}
+
+func TestTrainOOBCasesSplitsByFlag(t *testing.T) {
+	si := &SampleInfo{Train: []bool{true, false, true, false, false}}
+	train, oob := si.TrainOOBCases()
+	if got := len(train); got != 2 {
+		t.Errorf("len(train) = %v, want 2", got)
+	}
+	if got := len(oob); got != 3 {
+		t.Errorf("len(oob) = %v, want 3", got)
+	}
+	for _, i := range train {
+		if !si.Train[i] {
+			t.Errorf("train contains case %v, which is not flagged Train", i)
+		}
+	}
+	for _, i := range oob {
+		if si.Train[i] {
+			t.Errorf("oob contains case %v, which is flagged Train", i)
+		}
+	}
+}
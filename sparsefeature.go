@@ -0,0 +1,910 @@
+package CloudForest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+SparseNumFeature is a numeric Feature backed by a CSR-style (indices,
+values) pair instead of a dense, one-entry-per-case slice. Cases that are
+not listed in Indices are implicit zeros, which makes SparseNumFeature
+suitable for very wide, mostly-zero data (one-hot/bag-of-words columns,
+genotype dosages, etc.) that would be too large to hold as a
+DenseNumFeature.
+
+Indices is kept sorted ascending so that a single case's value can be found
+by binary search. BestSplit, ShuffleCases and ImputeMissing work from the
+stored (nonzero or missing) entries and the cases they are given, never
+from a dense slice of every case in the matrix.
+*/
+type SparseNumFeature struct {
+	Indices    []int     //case indices with a nonzero value, sorted ascending
+	Values     []float64 //Values[k] is the value for case Indices[k]
+	MissingIdx []int     //case indices whose value is missing, sorted ascending
+	Name       string
+	NCases     int
+}
+
+/*
+SparseCatFeature is a categorical Feature backed by a CSR-style
+(indices, codes) pair. Cases not listed in Indices are implicitly the
+feature's "absent"/reference category, which BestSplit treats as its own
+category alongside the feature's other observed levels.
+*/
+type SparseCatFeature struct {
+	*CatMap
+	Indices    []int //case indices with a non-reference category, sorted ascending
+	Codes      []int //Codes[k] is the category code for case Indices[k]
+	MissingIdx []int //case indices whose value is missing, sorted ascending
+	Name       string
+	Boolean    bool
+	NCases     int
+}
+
+const sparseAbsentCategory = "0"
+
+//-- SparseNumFeature -------------------------------------------------------
+
+func (f *SparseNumFeature) GetName() string { return f.Name }
+
+func (f *SparseNumFeature) findNonzero(i int) (k int, ok bool) {
+	k = sort.SearchInts(f.Indices, i)
+	return k, k < len(f.Indices) && f.Indices[k] == i
+}
+
+func (f *SparseNumFeature) isMissingIdx(i int) bool {
+	k := sort.SearchInts(f.MissingIdx, i)
+	return k < len(f.MissingIdx) && f.MissingIdx[k] == i
+}
+
+//IsMissing reports whether case i's value was recorded as missing, as
+//distinct from an implicit zero.
+func (f *SparseNumFeature) IsMissing(i int) bool { return f.isMissingIdx(i) }
+
+//value returns the effective value of case i (0 for both implicit and
+//explicit zeros) and whether it is missing.
+func (f *SparseNumFeature) value(i int) (v float64, missing bool) {
+	if f.isMissingIdx(i) {
+		return 0, true
+	}
+	if k, ok := f.findNonzero(i); ok {
+		return f.Values[k], false
+	}
+	return 0, false
+}
+
+func (f *SparseNumFeature) GetStr(i int) string {
+	if f.isMissingIdx(i) {
+		return "?"
+	}
+	v, _ := f.value(i)
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+//Append parses v as the next case's value. "?" and "NA" are treated as
+//missing; any other unparsable token is also recorded as missing.
+func (f *SparseNumFeature) Append(v string) {
+	i := f.NCases
+	f.NCases++
+	v = strings.TrimSpace(v)
+	if v == "?" || v == "NA" || v == "" {
+		f.MissingIdx = append(f.MissingIdx, i)
+		return
+	}
+	parsed, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		f.MissingIdx = append(f.MissingIdx, i)
+		return
+	}
+	if parsed != 0 {
+		f.Indices = append(f.Indices, i)
+		f.Values = append(f.Values, parsed)
+	}
+}
+
+//ImputeMissing replaces every missing value with the mean of the feature's
+//non-missing values (implicit zeros count towards both the sum and the
+//denominator) and clears the missing set.
+func (f *SparseNumFeature) ImputeMissing() {
+	if len(f.MissingIdx) == 0 {
+		return
+	}
+	nonMissing := f.NCases - len(f.MissingIdx)
+	if nonMissing <= 0 {
+		f.MissingIdx = nil
+		return
+	}
+	sum := 0.0
+	for _, v := range f.Values {
+		sum += v
+	}
+	mean := sum / float64(nonMissing)
+
+	rebuilt := f.rebuild()
+	for _, i := range f.MissingIdx {
+		rebuilt[i] = cell{mean, false}
+	}
+	f.fromRebuilt(rebuilt)
+	f.MissingIdx = nil
+}
+
+type cell struct {
+	v       float64
+	missing bool
+}
+
+//rebuild materializes the feature as a dense index->cell map so mutating
+//operations (ImputeMissing, ShuffleCases) can be expressed simply; it is
+//not used on the hot BestSplit path.
+func (f *SparseNumFeature) rebuild() map[int]cell {
+	m := make(map[int]cell, len(f.Indices)+len(f.MissingIdx))
+	for k, i := range f.Indices {
+		m[i] = cell{f.Values[k], false}
+	}
+	for _, i := range f.MissingIdx {
+		m[i] = cell{0, true}
+	}
+	return m
+}
+
+func (f *SparseNumFeature) fromRebuilt(m map[int]cell) {
+	indices := make([]int, 0, len(m))
+	for i, c := range m {
+		if c.missing || c.v != 0 {
+			indices = append(indices, i)
+		}
+	}
+	sort.Ints(indices)
+
+	f.Indices = f.Indices[:0]
+	f.Values = f.Values[:0]
+	f.MissingIdx = f.MissingIdx[:0]
+	for _, i := range indices {
+		c := m[i]
+		if c.missing {
+			f.MissingIdx = append(f.MissingIdx, i)
+			continue
+		}
+		f.Indices = append(f.Indices, i)
+		f.Values = append(f.Values, c.v)
+	}
+}
+
+//ShuffleCases randomly permutes the values (and missing flags) held by the
+//cases in *cases among themselves, leaving every other case untouched.
+//Every stored (nonzero or missing) entry is rebuilt, so the cost is
+//proportional to the stored entries plus len(*cases) rather than NCases.
+func (f *SparseNumFeature) ShuffleCases(cases *[]int) {
+	all := f.rebuild()
+	cs := *cases
+	shuffled := make([]cell, len(cs))
+	for i, c := range cs {
+		shuffled[i] = all[c]
+	}
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	for i, c := range cs {
+		all[c] = shuffled[i]
+	}
+	f.fromRebuilt(all)
+}
+
+//ShuffledCopy returns a copy of f with every case's value randomly
+//reassigned to another case, used to build contrast features.
+func (f *SparseNumFeature) ShuffledCopy() Feature {
+	fake := &SparseNumFeature{
+		Indices:    append([]int{}, f.Indices...),
+		Values:     append([]float64{}, f.Values...),
+		MissingIdx: append([]int{}, f.MissingIdx...),
+		Name:       f.Name + ":SHUFFLED",
+		NCases:     f.NCases,
+	}
+	cases := make([]int, f.NCases)
+	for i := range cases {
+		cases[i] = i
+	}
+	fake.ShuffleCases(&cases)
+	return fake
+}
+
+//CopyInTo overwrites other (which must be a *SparseNumFeature of the same
+//length) with f's data, reusing other's backing arrays where possible. It
+//is used by BestSplitter to seed the contrast target before each vetted
+//split search.
+func (f *SparseNumFeature) CopyInTo(other Feature) {
+	o := other.(*SparseNumFeature)
+	o.Indices = append(o.Indices[:0], f.Indices...)
+	o.Values = append(o.Values[:0], f.Values...)
+	o.MissingIdx = append(o.MissingIdx[:0], f.MissingIdx...)
+	o.Name = f.Name
+	o.NCases = f.NCases
+}
+
+//sparseEntries returns the (caseIndex, value, missing) triples for cases,
+//restricted to cases actually present (nonzero or missing) in f, plus the
+//cases that are implicit zeros. Cost is proportional to f's stored entries
+//plus len(cases), since every case is checked once for the zero list.
+func (f *SparseNumFeature) sparseEntries(cases []int) (present []cell, presentCase []int, zeroCases []int) {
+	inCases := make(map[int]bool, len(cases))
+	for _, c := range cases {
+		inCases[c] = true
+	}
+	seen := make(map[int]bool, len(f.Indices)+len(f.MissingIdx))
+	for k, i := range f.Indices {
+		if inCases[i] {
+			present = append(present, cell{f.Values[k], false})
+			presentCase = append(presentCase, i)
+			seen[i] = true
+		}
+	}
+	for _, i := range f.MissingIdx {
+		if inCases[i] {
+			present = append(present, cell{0, true})
+			presentCase = append(presentCase, i)
+			seen[i] = true
+		}
+	}
+	for _, c := range cases {
+		if !seen[c] {
+			zeroCases = append(zeroCases, c)
+		}
+	}
+	return
+}
+
+//BestSplit finds the numeric threshold over f's nonzero values within
+//*cases that best reduces target's impurity, treating the implicit zeros
+//as a single bucket inserted at its sorted rank. Missing cases are
+//excluded from threshold search, matching the dense feature convention of
+//sending them down the "missing" branch.
+func (f *SparseNumFeature) BestSplit(target Target, cases *[]int, parentImp float64, leafSize int, allocs *BestSplitAllocs) (interface{}, float64) {
+	present, presentCase, zeroCases := f.sparseEntries(*cases)
+
+	type point struct {
+		value float64
+		idx   int
+	}
+	points := make([]point, 0, len(present)+len(zeroCases))
+	missingCases := make([]int, 0)
+	for k, c := range present {
+		if c.missing {
+			missingCases = append(missingCases, presentCase[k])
+			continue
+		}
+		points = append(points, point{c.v, presentCase[k]})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].value < points[j].value })
+
+	if len(zeroCases) > 0 {
+		zeros := make([]point, len(zeroCases))
+		for i, c := range zeroCases {
+			zeros[i] = point{0, c}
+		}
+		inserted := false
+		withZero := make([]point, 0, len(points)+len(zeros))
+		for _, p := range points {
+			if !inserted && p.value > 0 {
+				withZero = append(withZero, zeros...)
+				inserted = true
+			}
+			withZero = append(withZero, p)
+		}
+		if !inserted {
+			withZero = append(withZero, zeros...)
+		}
+		points = withZero
+	}
+
+	if len(points) < 2*leafSize {
+		return nil, minImp
+	}
+
+	bestImp := minImp
+	bestThreshold := math.NaN()
+	left := make([]int, 0, len(points))
+	for i := 0; i < len(points)-1; i++ {
+		left = append(left, points[i].idx)
+		if points[i].value == points[i+1].value {
+			continue
+		}
+		if len(left) < leafSize || len(points)-len(left) < leafSize {
+			continue
+		}
+		right := make([]int, 0, len(points)-len(left))
+		for _, p := range points[len(left):] {
+			right = append(right, p.idx)
+		}
+		imp := parentImp - target.SplitImpurity(&left, &right, &missingCases, allocs)
+		if imp > bestImp {
+			bestImp = imp
+			bestThreshold = (points[i].value + points[i+1].value) / 2
+		}
+	}
+
+	if bestImp <= minImp {
+		return nil, minImp
+	}
+	return bestThreshold, bestImp
+}
+
+//DecodeSplit turns the raw threshold produced by BestSplit into a Splitter
+//that callers can apply with Split.
+func (f *SparseNumFeature) DecodeSplit(raw interface{}) *Splitter {
+	return &Splitter{
+		Feature:   f.Name,
+		Numerical: true,
+		Value:     raw.(float64),
+	}
+}
+
+//GoesLeft reports whether case i's value is below s.Value, implicit zeros
+//included, so that Splitter.Split can route cases without needing a
+//feature-type-specific branch of its own.
+func (f *SparseNumFeature) GoesLeft(i int, s *Splitter) bool {
+	v, _ := f.value(i)
+	return v < s.Value
+}
+
+//MissingVals reports whether f has any cases recorded as missing, as
+//distinct from an implicit zero.
+func (f *SparseNumFeature) MissingVals() bool { return len(f.MissingIdx) > 0 }
+
+//Impurity returns the variance of f's values over cases (implicit zeros
+//included), for use when a SparseNumFeature is itself a regression target.
+//counter is accepted only to satisfy Target; a regression variance has no
+//use for a count buffer.
+func (f *SparseNumFeature) Impurity(cases *[]int, counter *[]int) float64 {
+	present, _, zeroCases := f.sparseEntries(*cases)
+	n := 0
+	sum, sumSq := 0.0, 0.0
+	for _, c := range present {
+		if c.missing {
+			continue
+		}
+		n++
+		sum += c.v
+		sumSq += c.v * c.v
+	}
+	n += len(zeroCases)
+	if n < 2 {
+		return 0
+	}
+	mean := sum / float64(n)
+	return sumSq/float64(n) - mean*mean
+}
+
+//SplitImpurity reports the weighted impurity of the l/r/m partitions,
+//mirroring the convention used by the dense numeric target.
+func (f *SparseNumFeature) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) float64 {
+	total := float64(len(*l) + len(*r) + len(*m))
+	if total == 0 {
+		return 0
+	}
+	imp := float64(len(*l))/total*f.Impurity(l, allocs.Counter) +
+		float64(len(*r))/total*f.Impurity(r, allocs.Counter)
+	if len(*m) > 0 {
+		imp += float64(len(*m)) / total * f.Impurity(m, allocs.Counter)
+	}
+	return imp
+}
+
+//-- SparseCatFeature --------------------------------------------------------
+
+func (f *SparseCatFeature) GetName() string { return f.Name }
+
+func (f *SparseCatFeature) findNonzero(i int) (k int, ok bool) {
+	k = sort.SearchInts(f.Indices, i)
+	return k, k < len(f.Indices) && f.Indices[k] == i
+}
+
+func (f *SparseCatFeature) isMissingIdx(i int) bool {
+	k := sort.SearchInts(f.MissingIdx, i)
+	return k < len(f.MissingIdx) && f.MissingIdx[k] == i
+}
+
+func (f *SparseCatFeature) IsMissing(i int) bool { return f.isMissingIdx(i) }
+
+func (f *SparseCatFeature) code(i int) (code int, missing bool) {
+	if f.isMissingIdx(i) {
+		return 0, true
+	}
+	if k, ok := f.findNonzero(i); ok {
+		return f.Codes[k], false
+	}
+	return f.CatMap.CatToNum(sparseAbsentCategory), false
+}
+
+func (f *SparseCatFeature) GetStr(i int) string {
+	if f.isMissingIdx(i) {
+		return "?"
+	}
+	code, _ := f.code(i)
+	return f.CatMap.NumToCat(code)
+}
+
+//Append records the next case's category, treating "?" as missing and
+//sparseAbsentCategory ("0") as an implicit, unstored entry.
+func (f *SparseCatFeature) Append(v string) {
+	i := f.NCases
+	f.NCases++
+	v = strings.TrimSpace(v)
+	if v == "?" || v == "" {
+		f.MissingIdx = append(f.MissingIdx, i)
+		return
+	}
+	code := f.CatMap.CatToNum(v)
+	if v != sparseAbsentCategory {
+		f.Indices = append(f.Indices, i)
+		f.Codes = append(f.Codes, code)
+	}
+}
+
+//ImputeMissing replaces every missing category with the feature's mode
+//(including implicit-absent cases in the tally) and clears the missing set.
+func (f *SparseCatFeature) ImputeMissing() {
+	if len(f.MissingIdx) == 0 {
+		return
+	}
+	counts := make(map[int]int)
+	absentCode := f.CatMap.CatToNum(sparseAbsentCategory)
+	counts[absentCode] += f.NCases - len(f.Indices) - len(f.MissingIdx)
+	for _, c := range f.Codes {
+		counts[c]++
+	}
+	mode, modeCount := absentCode, -1
+	for code, n := range counts {
+		if n > modeCount {
+			mode, modeCount = code, n
+		}
+	}
+
+	rebuilt := make(map[int]int, len(f.Indices))
+	for k, i := range f.Indices {
+		rebuilt[i] = f.Codes[k]
+	}
+	for _, i := range f.MissingIdx {
+		rebuilt[i] = mode
+	}
+
+	indices := make([]int, 0, len(rebuilt))
+	for i, code := range rebuilt {
+		if code != absentCode {
+			indices = append(indices, i)
+		}
+	}
+	sort.Ints(indices)
+	f.Indices = indices
+	f.Codes = make([]int, len(indices))
+	for k, i := range indices {
+		f.Codes[k] = rebuilt[i]
+	}
+	f.MissingIdx = nil
+}
+
+//ShuffleCases randomly permutes the categories held by the cases in
+//*cases among themselves.
+func (f *SparseCatFeature) ShuffleCases(cases *[]int) {
+	absentCode := f.CatMap.CatToNum(sparseAbsentCategory)
+	cur := make(map[int]int, len(*cases))
+	miss := make(map[int]bool, len(*cases))
+	for _, i := range *cases {
+		code, m := f.code(i)
+		cur[i] = code
+		miss[i] = m
+	}
+
+	cs := *cases
+	codes := make([]int, len(cs))
+	missing := make([]bool, len(cs))
+	for k, c := range cs {
+		codes[k] = cur[c]
+		missing[k] = miss[c]
+	}
+	rand.Shuffle(len(cs), func(i, j int) {
+		codes[i], codes[j] = codes[j], codes[i]
+		missing[i], missing[j] = missing[j], missing[i]
+	})
+
+	byIdx := f.asMap()
+	for k, c := range cs {
+		if missing[k] {
+			delete(byIdx.codes, c)
+			byIdx.missing[c] = true
+			continue
+		}
+		delete(byIdx.missing, c)
+		if codes[k] == absentCode {
+			delete(byIdx.codes, c)
+		} else {
+			byIdx.codes[c] = codes[k]
+		}
+	}
+	f.fromMap(byIdx)
+}
+
+type sparseCatMap struct {
+	codes   map[int]int
+	missing map[int]bool
+}
+
+func (f *SparseCatFeature) asMap() *sparseCatMap {
+	m := &sparseCatMap{codes: make(map[int]int, len(f.Indices)), missing: make(map[int]bool, len(f.MissingIdx))}
+	for k, i := range f.Indices {
+		m.codes[i] = f.Codes[k]
+	}
+	for _, i := range f.MissingIdx {
+		m.missing[i] = true
+	}
+	return m
+}
+
+func (f *SparseCatFeature) fromMap(m *sparseCatMap) {
+	indices := make([]int, 0, len(m.codes))
+	for i := range m.codes {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	f.Indices = indices
+	f.Codes = make([]int, len(indices))
+	for k, i := range indices {
+		f.Codes[k] = m.codes[i]
+	}
+
+	missing := make([]int, 0, len(m.missing))
+	for i := range m.missing {
+		missing = append(missing, i)
+	}
+	sort.Ints(missing)
+	f.MissingIdx = missing
+}
+
+func (f *SparseCatFeature) ShuffledCopy() Feature {
+	fake := &SparseCatFeature{
+		CatMap:     f.CatMap,
+		Indices:    append([]int{}, f.Indices...),
+		Codes:      append([]int{}, f.Codes...),
+		MissingIdx: append([]int{}, f.MissingIdx...),
+		Name:       f.Name + ":SHUFFLED",
+		Boolean:    f.Boolean,
+		NCases:     f.NCases,
+	}
+	cases := make([]int, f.NCases)
+	for i := range cases {
+		cases[i] = i
+	}
+	fake.ShuffleCases(&cases)
+	return fake
+}
+
+func (f *SparseCatFeature) CopyInTo(other Feature) {
+	o := other.(*SparseCatFeature)
+	o.CatMap = f.CatMap
+	o.Indices = append(o.Indices[:0], f.Indices...)
+	o.Codes = append(o.Codes[:0], f.Codes...)
+	o.MissingIdx = append(o.MissingIdx[:0], f.MissingIdx...)
+	o.Name = f.Name
+	o.Boolean = f.Boolean
+	o.NCases = f.NCases
+}
+
+//BestSplit finds the binary category-set split over f's observed
+//categories within *cases (including the implicit "absent" category) that
+//best reduces target's impurity.
+//
+//Rather than evaluating every one of the 2^(k-1)-1 ways to bipartition the
+//k observed categories, categories are first ordered by their mean target
+//response (Breiman's ordering) and only the k-1 contiguous splits of that
+//ordering are evaluated. This is the optimal search for a binary or
+//numeric target and a good approximation otherwise, and keeps the search
+//linear in the number of observed categories instead of exponential -
+//important for the wide, high-cardinality one-hot columns this type
+//targets.
+func (f *SparseCatFeature) BestSplit(target Target, cases *[]int, parentImp float64, leafSize int, allocs *BestSplitAllocs) (interface{}, float64) {
+	groups := make(map[int][]int)
+	missingCases := make([]int, 0)
+	for _, i := range *cases {
+		code, missing := f.code(i)
+		if missing {
+			missingCases = append(missingCases, i)
+			continue
+		}
+		groups[code] = append(groups[code], i)
+	}
+	if len(groups) < 2 {
+		return nil, minImp
+	}
+
+	codes := make([]int, 0, len(groups))
+	for c := range groups {
+		codes = append(codes, c)
+	}
+	means := make(map[int]float64, len(codes))
+	for _, c := range codes {
+		means[c] = meanTargetValue(target, groups[c])
+	}
+	sort.Slice(codes, func(i, j int) bool { return means[codes[i]] < means[codes[j]] })
+
+	bestImp := minImp
+	var bestLeftSet map[int]bool
+	left := make([]int, 0, len(*cases))
+	leftSet := make(map[int]bool, len(codes))
+	for i := 0; i < len(codes)-1; i++ {
+		left = append(left, groups[codes[i]]...)
+		leftSet[codes[i]] = true
+		if len(left) < leafSize || len(*cases)-len(left) < leafSize {
+			continue
+		}
+		right := make([]int, 0, len(*cases)-len(left))
+		for _, c := range codes[i+1:] {
+			right = append(right, groups[c]...)
+		}
+		imp := parentImp - target.SplitImpurity(&left, &right, &missingCases, allocs)
+		if imp > bestImp {
+			bestImp = imp
+			bestLeftSet = make(map[int]bool, len(leftSet))
+			for c := range leftSet {
+				bestLeftSet[c] = true
+			}
+		}
+	}
+
+	if bestImp <= minImp {
+		return nil, minImp
+	}
+	left2 := make(map[string]bool, len(bestLeftSet))
+	for c := range bestLeftSet {
+		left2[f.CatMap.NumToCat(c)] = true
+	}
+	return left2, bestImp
+}
+
+//meanTargetValue returns the mean response of target over cases, using
+//each feature type's numeric value (a category's numeric code stands in
+//for a classification target, since only the relative order matters here).
+func meanTargetValue(target Target, cases []int) float64 {
+	if len(cases) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, i := range cases {
+		switch t := target.(type) {
+		case *DenseNumFeature:
+			if !t.Missing[i] {
+				sum += t.NumData[i]
+			}
+		case *DenseCatFeature:
+			if !t.Missing[i] {
+				sum += float64(t.CatData[i])
+			}
+		case *SparseNumFeature:
+			v, missing := t.value(i)
+			if !missing {
+				sum += v
+			}
+		case *SparseCatFeature:
+			code, missing := t.code(i)
+			if !missing {
+				sum += float64(code)
+			}
+		}
+	}
+	return sum / float64(len(cases))
+}
+
+func (f *SparseCatFeature) DecodeSplit(raw interface{}) *Splitter {
+	return &Splitter{
+		Feature:   f.Name,
+		Numerical: false,
+		Left:      raw.(map[string]bool),
+	}
+}
+
+//GoesLeft reports whether case i's category (implicit "absent" entries
+//included) is in s.Left, so that Splitter.Split can route cases without
+//needing a feature-type-specific branch of its own.
+func (f *SparseCatFeature) GoesLeft(i int, s *Splitter) bool {
+	code, _ := f.code(i)
+	return s.Left[f.CatMap.NumToCat(code)]
+}
+
+//MissingVals reports whether f has any cases recorded as missing, as
+//distinct from the implicit "absent" category.
+func (f *SparseCatFeature) MissingVals() bool { return len(f.MissingIdx) > 0 }
+
+//Impurity returns the Gini impurity of f's categories over cases
+//(implicit "absent" entries included), for use when a SparseCatFeature is
+//itself a classification target.
+func (f *SparseCatFeature) Impurity(cases *[]int, counter *[]int) float64 {
+	counts := (*counter)[:0]
+	n := 0
+	for _, i := range *cases {
+		code, missing := f.code(i)
+		if missing {
+			continue
+		}
+		for len(counts) <= code {
+			counts = append(counts, 0)
+		}
+		counts[code]++
+		n++
+	}
+	*counter = counts
+	if n == 0 {
+		return 0
+	}
+	gini := 1.0
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(n)
+		gini -= p * p
+	}
+	return gini
+}
+
+func (f *SparseCatFeature) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) float64 {
+	total := float64(len(*l) + len(*r) + len(*m))
+	if total == 0 {
+		return 0
+	}
+	imp := float64(len(*l))/total*f.Impurity(l, allocs.Counter) +
+		float64(len(*r))/total*f.Impurity(r, allocs.Counter)
+	if len(*m) > 0 {
+		imp += float64(len(*m)) / total * f.Impurity(m, allocs.Counter)
+	}
+	return imp
+}
+
+//-- loading & conversion ----------------------------------------------------
+
+/*
+ParseLibSVMSparse reads a libsvm formatted file ("label i1:v1 i2:v2 ...",
+1-indexed features) directly into sparse features, unlike ParseLibSVM which
+materializes a dense FeatureMatrix. The first token of every line becomes
+case 0's, case 1's, etc. label and is stored as a categorical feature named
+"label" (kept categorical since libsvm labels are typically class ids);
+every feature column referenced by an index becomes a SparseNumFeature
+named "N:<index>".
+*/
+func ParseLibSVMSparse(r io.Reader) (*FeatureMatrix, error) {
+	scanner := bufio.NewScanner(r)
+	lines := make([][]string, 0)
+	maxIndex := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		lines = append(lines, fields)
+		for _, tok := range fields[1:] {
+			parts := strings.SplitN(tok, ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("libsvm: bad index:value token %q", tok)
+			}
+			idx, err := strconv.Atoi(parts[0])
+			if err != nil || idx < 1 {
+				return nil, fmt.Errorf("libsvm: bad index %q", tok)
+			}
+			if idx > maxIndex {
+				maxIndex = idx
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	label := &SparseCatFeature{CatMap: &CatMap{make(map[string]int), make([]string, 0)}, Name: "label"}
+	cols := make([]*SparseNumFeature, maxIndex)
+	for i := range cols {
+		cols[i] = &SparseNumFeature{Name: fmt.Sprintf("N:%v", i+1)}
+	}
+
+	data := make([]Feature, 0, maxIndex+1)
+	lookup := make(map[string]int, maxIndex+1)
+	data = append(data, label)
+	lookup[label.Name] = 0
+	for i, c := range cols {
+		data = append(data, c)
+		lookup[c.Name] = i + 1
+	}
+
+	caseLabels := make([]string, 0, len(lines))
+	for n, fields := range lines {
+		caseLabels = append(caseLabels, fmt.Sprintf("%v", n))
+		label.Append(fields[0])
+		seen := make(map[int]bool, len(fields)-1)
+		for _, tok := range fields[1:] {
+			parts := strings.SplitN(tok, ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("libsvm: bad index:value token %q", tok)
+			}
+			idx, err := strconv.Atoi(parts[0])
+			if err != nil || idx < 1 || idx > maxIndex {
+				return nil, fmt.Errorf("libsvm: bad index %q", tok)
+			}
+			cols[idx-1].Append(parts[1])
+			seen[idx-1] = true
+		}
+		for i, c := range cols {
+			if !seen[i] {
+				c.Append("0")
+			}
+		}
+	}
+
+	return &FeatureMatrix{data, lookup, caseLabels}, nil
+}
+
+/*
+Densify converts every sparse feature in fm whose fraction of nonzero (or
+non-reference-category) entries is at least threshold into the equivalent dense
+feature, leaving sparser columns untouched. It returns the names of the
+features that were converted. Dense features are faster to split on once a
+column is no longer mostly zero, since dense iteration has no per-case
+lookup overhead.
+*/
+func (fm *FeatureMatrix) Densify(threshold float64) (densified []string) {
+	for i, f := range fm.Data {
+		switch sf := f.(type) {
+		case *SparseNumFeature:
+			density := float64(len(sf.Indices)) / float64(maxInt(sf.NCases, 1))
+			if density < threshold {
+				continue
+			}
+			fm.Data[i] = sf.toDense()
+			densified = append(densified, sf.Name)
+		case *SparseCatFeature:
+			density := float64(len(sf.Indices)) / float64(maxInt(sf.NCases, 1))
+			if density < threshold {
+				continue
+			}
+			fm.Data[i] = sf.toDense()
+			densified = append(densified, sf.Name)
+		}
+	}
+	return
+}
+
+func (f *SparseNumFeature) toDense() *DenseNumFeature {
+	data := make([]float64, f.NCases)
+	missing := make([]bool, f.NCases)
+	for k, i := range f.Indices {
+		data[i] = f.Values[k]
+	}
+	for _, i := range f.MissingIdx {
+		missing[i] = true
+	}
+	return &DenseNumFeature{data, missing, f.Name, len(f.MissingIdx) > 0}
+}
+
+func (f *SparseCatFeature) toDense() *DenseCatFeature {
+	data := make([]int, f.NCases)
+	missing := make([]bool, f.NCases)
+	absentCode := f.CatMap.CatToNum(sparseAbsentCategory)
+	for i := range data {
+		data[i] = absentCode
+	}
+	for k, i := range f.Indices {
+		data[i] = f.Codes[k]
+	}
+	for _, i := range f.MissingIdx {
+		missing[i] = true
+	}
+	return &DenseCatFeature{f.CatMap, data, missing, f.Name, false, len(f.MissingIdx) > 0}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,183 @@
+package CloudForest
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+//recordingTarget is a minimal Target stub that records the l/r/m case sets
+//every SplitImpurity call was made with, so BestSplit's case accounting can
+//be checked without depending on a real impurity computation.
+type recordingTarget struct {
+	impurity float64
+	calls    [][3][]int
+}
+
+func (r *recordingTarget) Impurity(cases *[]int, counter *[]int) float64 { return r.impurity }
+
+func (r *recordingTarget) SplitImpurity(l *[]int, right *[]int, m *[]int, allocs *BestSplitAllocs) float64 {
+	r.calls = append(r.calls, [3][]int{
+		append([]int{}, (*l)...),
+		append([]int{}, (*right)...),
+		append([]int{}, (*m)...),
+	})
+	return 0
+}
+
+//assertFullCaseCoverage fails the test unless the union of l, r and m from
+//every recorded SplitImpurity call is exactly want, with no duplicates or
+//omissions - in particular, that missing cases are neither dropped nor
+//double-counted.
+func assertFullCaseCoverage(t *testing.T, calls [][3][]int, want []int) {
+	t.Helper()
+	if len(calls) == 0 {
+		t.Fatal("SplitImpurity was never called")
+	}
+	wantSorted := append([]int{}, want...)
+	sort.Ints(wantSorted)
+	for i, call := range calls {
+		got := append(append(append([]int{}, call[0]...), call[1]...), call[2]...)
+		sort.Ints(got)
+		if len(got) != len(wantSorted) {
+			t.Fatalf("call %v: l+r+m covers %v cases, want %v (got %v, want %v)", i, len(got), len(wantSorted), got, wantSorted)
+		}
+		for k := range got {
+			if got[k] != wantSorted[k] {
+				t.Fatalf("call %v: l+r+m = %v, want %v", i, got, wantSorted)
+			}
+		}
+	}
+}
+
+func TestParseLibSVMSparseRejectsTokenWithoutColon(t *testing.T) {
+	r := strings.NewReader("1 3:5\n0 2\n")
+	if _, err := ParseLibSVMSparse(r); err == nil {
+		t.Fatal("ParseLibSVMSparse should error on a token with no \":value\" part, not panic")
+	}
+}
+
+func TestParseLibSVMSparseRejectsNonPositiveIndex(t *testing.T) {
+	r := strings.NewReader("1 0:5\n")
+	if _, err := ParseLibSVMSparse(r); err == nil {
+		t.Fatal("ParseLibSVMSparse should error on a 0 feature index, not panic")
+	}
+
+	r = strings.NewReader("1 -1:5\n")
+	if _, err := ParseLibSVMSparse(r); err == nil {
+		t.Fatal("ParseLibSVMSparse should error on a negative feature index, not panic")
+	}
+}
+
+func TestParseLibSVMSparseHappyPath(t *testing.T) {
+	r := strings.NewReader("1 1:5 3:2\n0 2:1\n")
+	fm, err := ParseLibSVMSparse(r)
+	if err != nil {
+		t.Fatalf("ParseLibSVMSparse: %v", err)
+	}
+	if len(fm.Data) != 4 { // label + 3 feature columns
+		t.Fatalf("len(fm.Data) = %v, want 4", len(fm.Data))
+	}
+	col1 := fm.Data[fm.Map["N:1"]].(*SparseNumFeature)
+	if got := col1.GetStr(0); got != "5" {
+		t.Errorf("N:1 case 0 = %v, want 5", got)
+	}
+	if got := col1.GetStr(1); got != "0" {
+		t.Errorf("N:1 case 1 = %v, want 0 (implicit zero)", got)
+	}
+}
+
+func TestSparseNumFeatureGoesLeftAndMissingVals(t *testing.T) {
+	f := &SparseNumFeature{Name: "x"}
+	f.Append("5")
+	f.Append("0")
+	f.Append("?")
+
+	if f.MissingVals() != true {
+		t.Fatal("MissingVals should report the appended \"?\" as missing")
+	}
+	left := &Splitter{Feature: "x", Numerical: true, Value: 3}
+	if f.GoesLeft(0, left) {
+		t.Error("case 0 (value 5) should not go left of threshold 3")
+	}
+	if !f.GoesLeft(1, left) {
+		t.Error("case 1 (implicit zero) should go left of threshold 3")
+	}
+}
+
+func TestSparseCatFeatureGoesLeft(t *testing.T) {
+	f := &SparseCatFeature{CatMap: &CatMap{make(map[string]int), make([]string, 0)}, Name: "c"}
+	f.Append("a")
+	f.Append("0")
+
+	s := f.DecodeSplit(map[string]bool{"a": true})
+	if !f.GoesLeft(0, s) {
+		t.Error("case 0 (category \"a\") should go left when \"a\" is in the left set")
+	}
+	if f.GoesLeft(1, s) {
+		t.Error("case 1 (absent/reference category) should not go left when only \"a\" is in the left set")
+	}
+}
+
+func TestSparseNumFeatureBestSplitAccountsForMissingCases(t *testing.T) {
+	f := &SparseNumFeature{Name: "x"}
+	f.Append("1") //case 0
+	f.Append("2") //case 1
+	f.Append("3") //case 2
+	f.Append("?") //case 3, missing
+	f.Append("4") //case 4
+	f.Append("5") //case 5
+
+	target := &recordingTarget{impurity: 10}
+	cases := []int{0, 1, 2, 3, 4, 5}
+	f.BestSplit(target, &cases, 10, 1, &BestSplitAllocs{})
+
+	assertFullCaseCoverage(t, target.calls, cases)
+	for i, call := range target.calls {
+		m := call[2]
+		if len(m) != 1 || m[0] != 3 {
+			t.Errorf("call %v: missing set = %v, want [3]", i, m)
+		}
+	}
+}
+
+func TestSparseCatFeatureBestSplitAccountsForMissingCases(t *testing.T) {
+	f := &SparseCatFeature{CatMap: &CatMap{make(map[string]int), make([]string, 0)}, Name: "c"}
+	f.Append("a") //case 0
+	f.Append("b") //case 1
+	f.Append("0") //case 2, reference category
+	f.Append("?") //case 3, missing
+	f.Append("a") //case 4
+	f.Append("b") //case 5
+
+	target := &recordingTarget{impurity: 10}
+	cases := []int{0, 1, 2, 3, 4, 5}
+	f.BestSplit(target, &cases, 10, 1, &BestSplitAllocs{})
+
+	assertFullCaseCoverage(t, target.calls, cases)
+	for i, call := range target.calls {
+		m := call[2]
+		if len(m) != 1 || m[0] != 3 {
+			t.Errorf("call %v: missing set = %v, want [3]", i, m)
+		}
+	}
+}
+
+func TestDensifyConvertsDenseEnoughSparseColumn(t *testing.T) {
+	f := &SparseNumFeature{Name: "x"}
+	for i := 0; i < 4; i++ {
+		f.Append("1")
+	}
+	fm := &FeatureMatrix{
+		Data:       []Feature{f},
+		Map:        map[string]int{"x": 0},
+		CaseLabels: []string{"a", "b", "c", "d"},
+	}
+	densified := fm.Densify(0.5)
+	if len(densified) != 1 || densified[0] != "x" {
+		t.Fatalf("Densify = %v, want [x]", densified)
+	}
+	if _, ok := fm.Data[0].(*DenseNumFeature); !ok {
+		t.Fatalf("fm.Data[0] is %T, want *DenseNumFeature", fm.Data[0])
+	}
+}